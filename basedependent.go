@@ -1,6 +1,7 @@
 package framework
 
 import (
+	"context"
 	"fmt"
 	"halkyon.io/api/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -13,6 +14,7 @@ import (
 type BaseDependentResource struct {
 	config DependentResourceConfig
 	owner  SerializableResource
+	helper *K8SHelper
 }
 
 // NewBaseDependentResource creates a new BaseDependentResource of the specified dependent type and with the specified owner.
@@ -33,12 +35,18 @@ func NewConfiguredBaseDependentResource(owner SerializableResource, config Depen
 // DefaultFetcher provides a default mechanism to fetch latest Object state underlying the specified DependentResource from the
 // cluster.
 func DefaultFetcher(dep DependentResource) (runtime.Object, error) {
+	return DefaultFetcherWithContext(context.TODO(), dep)
+}
+
+// DefaultFetcherWithContext is the context-aware version of DefaultFetcher
+func DefaultFetcherWithContext(ctx context.Context, dep DependentResource) (runtime.Object, error) {
 	config := dep.GetConfig()
-	into, err := Helper.Scheme.New(config.GroupVersionKind)
+	helper := HelperFor(dep)
+	into, err := helper.Scheme.New(config.GroupVersionKind)
 	if err != nil {
 		return nil, err
 	}
-	return Helper.Fetch(dep.Name(), dep.Owner().GetNamespace(), into)
+	return helper.FetchWithContext(ctx, dep.Name(), dep.Owner().GetNamespace(), into)
 }
 
 // DefaultDependentResourceNameFor returns a default name for a DependentResource for a given owner.
@@ -104,3 +112,16 @@ func (b BaseDependentResource) GetConfig() DependentResourceConfig {
 func (b BaseDependentResource) Owner() SerializableResource {
 	return b.owner
 }
+
+// GetHelper returns the K8SHelper this BaseDependentResource was given, if any, or the one its owner uses otherwise
+func (b BaseDependentResource) GetHelper() *K8SHelper {
+	if b.helper != nil {
+		return b.helper
+	}
+	return HelperFor(b.owner)
+}
+
+// SetHelper sets the K8SHelper this BaseDependentResource uses to interact with the cluster
+func (b *BaseDependentResource) SetHelper(helper *K8SHelper) {
+	b.helper = helper
+}
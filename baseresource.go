@@ -1,6 +1,7 @@
 package framework
 
 import (
+	"context"
 	"fmt"
 	"halkyon.io/api/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -11,6 +12,7 @@ type BaseResource struct {
 	v1beta1.StatusAware
 	dependents []DependentResource
 	requeue    bool
+	helper     *K8SHelper
 }
 
 func (b *BaseResource) SetNeedsRequeue(requeue bool) {
@@ -28,8 +30,13 @@ func NewBaseResource(statusAware v1beta1.StatusAware) *BaseResource {
 
 // CreateOrUpdateDependents calls CreateOrUpdate on the dependents of the associated BaseResource
 func (b *BaseResource) CreateOrUpdateDependents() error {
+	return b.CreateOrUpdateDependentsWithContext(context.TODO())
+}
+
+// CreateOrUpdateDependentsWithContext is the context-aware version of CreateOrUpdateDependents
+func (b *BaseResource) CreateOrUpdateDependentsWithContext(ctx context.Context) error {
 	for _, dep := range b.dependents {
-		if e := CreateOrUpdate(dep); e != nil {
+		if e := CreateOrUpdateWithContext(ctx, dep); e != nil {
 			// wrap error so that downstream client can process the original error based on needs
 			return fmt.Errorf("failed to create or update '%s' %s: %w", dep.Name(), dep.GetConfig().TypeName, e)
 		}
@@ -66,35 +73,68 @@ func (b *BaseResource) GetDependent(predicate Predicate) (DependentResource, err
 // FetchUpdatedDependent fetches the latest cluster state of the Object associated with the DependentResource identified by the
 // specified predicate. As it calls GetDependent, it returns the same error conditions.
 func (b *BaseResource) FetchUpdatedDependent(predicate Predicate) (runtime.Object, error) {
+	return b.FetchUpdatedDependentWithContext(context.TODO(), predicate)
+}
+
+// FetchUpdatedDependentWithContext is the context-aware version of FetchUpdatedDependent
+func (b *BaseResource) FetchUpdatedDependentWithContext(ctx context.Context, predicate Predicate) (runtime.Object, error) {
 	dependent, err := b.GetDependent(predicate)
 	if err != nil {
 		return nil, err
 	}
-	return dependent.Fetch()
+	return WithContext(dependent).FetchWithContext(ctx)
 }
 
 // AddDependentResource adds dependent resources to this base resource, keeping the order in which they are added, it is
-// therefore possible to create dependent resources in a specific order since they are created in the same order as specified here
+// therefore possible to create dependent resources in a specific order since they are created in the same order as specified here.
+// HelperAware dependents are set to use the K8SHelper of this BaseResource, if it was given one.
 func (b *BaseResource) AddDependentResource(resources ...DependentResource) []DependentResource {
 	for _, dependent := range resources {
 		if dependent.Owner() == nil {
 			panic(fmt.Errorf("dependent resource %s must have an owner", dependent.Name()))
 		}
+		if aware, ok := dependent.(HelperAware); ok && b.helper != nil {
+			aware.SetHelper(b.helper)
+		}
 		b.dependents = append(b.dependents, dependent)
 	}
 	return b.dependents
 }
 
+// GetHelper returns the K8SHelper this BaseResource uses to interact with the cluster, defaulting to Helper
+func (b *BaseResource) GetHelper() *K8SHelper {
+	if b.helper == nil {
+		return &Helper
+	}
+	return b.helper
+}
+
+// SetHelper sets the K8SHelper this BaseResource, and its HelperAware dependents, use to interact with the cluster
+func (b *BaseResource) SetHelper(helper *K8SHelper) {
+	b.helper = helper
+	for _, dependent := range b.dependents {
+		if aware, ok := dependent.(HelperAware); ok {
+			aware.SetHelper(helper)
+		}
+	}
+}
+
 // ComputeStatus computes the aggregated status of this BaseResource based on the status of each DependentResource that declares
 // that it needs to be checked for readiness.
 func (b *BaseResource) ComputeStatus() (needsUpdate bool) {
+	return b.ComputeStatusWithContext(context.TODO())
+}
+
+// ComputeStatusWithContext is the context-aware version of ComputeStatus
+func (b *BaseResource) ComputeStatusWithContext(ctx context.Context) (needsUpdate bool) {
 	// todo: compute whether we need to update the resource
 	status := b.GetStatus()
-	for _, dependent := range b.dependents {
-		config := dependent.GetConfig()
+	for _, d := range b.dependents {
+		config := d.GetConfig()
 		if config.CheckedForReadiness {
-			fetched, err := dependent.Fetch()
-			condition := dependent.GetCondition(fetched, err)
+			dependent := WithContext(d)
+			fetched, err := dependent.FetchWithContext(ctx)
+			condition := dependent.GetConditionWithContext(ctx, fetched, err)
 			needsUpdate = needsUpdate || status.SetCondition(condition)
 		}
 	}
@@ -104,12 +144,15 @@ func (b *BaseResource) ComputeStatus() (needsUpdate bool) {
 	return
 }
 
+// failedReason is the status reason DefaultErrorHandler sets when an error occurs
+const failedReason = "Failed"
+
 // DefaultErrorHandler updates the specified status based on the given error if needed, returning whether the status was updated
 // along with the updated status to be used by calling code.
 func DefaultErrorHandler(status v1beta1.Status, err error) (updated bool, updatedStatus v1beta1.Status) {
 	errMsg := err.Error()
-	if "Failed" != status.Reason || errMsg != status.Message {
-		status.Reason = "Failed"
+	if failedReason != status.Reason || errMsg != status.Message {
+		status.Reason = failedReason
 		status.Message = errMsg
 		return true, status
 	}
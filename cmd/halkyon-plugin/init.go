@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"halkyon.io/operator-framework/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const defaultAPIVersion = "v1.0.0-rc.6"
+
+// frameworkVersion returns the version of the operator framework this command was built from, i.e. the tag or pseudo-version
+// it was installed at, which generated plugins depend on by default. It returns the empty string if the version can't be
+// determined, e.g. when the command is built from a local checkout.
+func frameworkVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return ""
+}
+
+// pluginSpec describes the plugin to generate
+type pluginSpec struct {
+	// Module is the path of the generated go module
+	Module string
+	// Name is the name of the plugin binary
+	Name string
+	// Category is the capability category supported by the plugin
+	Category string
+	// Type is the capability type supported by the plugin
+	Type string
+	// Versions lists the version ranges of the capability type supported by the plugin
+	Versions []string
+	// FrameworkVersion is the version of the operator framework the generated module depends on
+	FrameworkVersion string
+	// APIVersion is the version of the Halkyon API the generated module depends on
+	APIVersion string
+}
+
+// Ident returns the exported Go identifier prefix used to name the generated types
+func (s pluginSpec) Ident() string {
+	return identifierFor(s.Type)
+}
+
+// SampleVersion returns the version used by the sample capabilities of the generated tests
+func (s pluginSpec) SampleVersion() string {
+	if len(s.Versions) == 0 {
+		return ""
+	}
+	return s.Versions[0]
+}
+
+func (s pluginSpec) validate() error {
+	if len(s.Category) == 0 {
+		return fmt.Errorf("a capability category is required")
+	}
+	if len(s.Type) == 0 {
+		return fmt.Errorf("a capability type is required")
+	}
+	if len(s.Ident()) == 0 {
+		return fmt.Errorf("capability type '%s' doesn't contain any letter or digit", s.Type)
+	}
+	if len(s.FrameworkVersion) == 0 {
+		return fmt.Errorf("couldn't determine the operator framework version to depend on, specify it using -framework-version")
+	}
+	// go modules only accept semantic versions in go.mod, branch names need to be resolved to pseudo-versions first
+	if !strings.HasPrefix(s.FrameworkVersion, "v") {
+		return fmt.Errorf("invalid framework version '%s': a tag or pseudo-version, e.g. 'v1.0.0', is required", s.FrameworkVersion)
+	}
+	for _, version := range s.Versions {
+		if _, err := util.ParseVersionRange(version); err != nil {
+			return fmt.Errorf("invalid version '%s': %v", version, err)
+		}
+	}
+	return nil
+}
+
+// runInit implements the init command, generating a plugin module in the specified directory
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	spec := pluginSpec{}
+	flags.StringVar(&spec.Category, "category", "", "capability category supported by the plugin, e.g. 'database' (required)")
+	flags.StringVar(&spec.Type, "type", "", "capability type supported by the plugin, e.g. 'postgres' (required)")
+	versions := flags.String("versions", "", "comma-separated version ranges of the capability type supported by the plugin, e.g. '^10, 11'")
+	flags.StringVar(&spec.Module, "module", "", "path of the generated go module, defaults to the plugin name")
+	flags.StringVar(&spec.Name, "name", "", "name of the plugin binary, defaults to '<type>-plugin'")
+	flags.StringVar(&spec.FrameworkVersion, "framework-version", frameworkVersion(), "version (tag or pseudo-version) of the operator framework to depend on, defaults to the version of this command")
+	flags.StringVar(&spec.APIVersion, "api-version", defaultAPIVersion, "version of the Halkyon API to depend on")
+	force := flags.Bool("force", false, "overwrite existing files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: halkyon-plugin init -category <category> -type <type> [flags] [directory]")
+		fmt.Fprintln(flags.Output(), "\nGenerates a new capability plugin module in the specified directory, defaulting to the plugin name.")
+		fmt.Fprintln(flags.Output(), "\nFlags:")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args()[1:], " "))
+	}
+
+	for _, version := range strings.Split(*versions, ",") {
+		if version = strings.TrimSpace(version); len(version) > 0 {
+			spec.Versions = append(spec.Versions, version)
+		}
+	}
+	if len(spec.Name) == 0 {
+		spec.Name = strings.ToLower(spec.Type) + "-plugin"
+	}
+	if len(spec.Module) == 0 {
+		spec.Module = spec.Name
+	}
+	if err := spec.validate(); err != nil {
+		flags.Usage()
+		return err
+	}
+
+	dir := flags.Arg(0)
+	if len(dir) == 0 {
+		dir = spec.Name
+	}
+	if err := generate(dir, spec, *force); err != nil {
+		return err
+	}
+	fmt.Printf("generated '%s' plugin in '%s', run 'make' in this directory to test and build it\n", spec.Name, dir)
+	return nil
+}
+
+// generate renders the plugin templates for the specified pluginSpec in the given directory, refusing to overwrite existing
+// files unless forced to
+func generate(dir string, spec pluginSpec, force bool) error {
+	rendered := make(map[string][]byte, len(pluginTemplates))
+	for name, text := range pluginTemplates {
+		content, err := render(name, text, spec)
+		if err != nil {
+			return err
+		}
+		rendered[name] = content
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil && !force {
+			return fmt.Errorf("'%s' already exists, use -force to overwrite it", filepath.Join(dir, name))
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, content := range rendered {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func render(name, text string, spec pluginSpec) ([]byte, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, spec); err != nil {
+		return nil, fmt.Errorf("couldn't render %s: %v", name, err)
+	}
+	if filepath.Ext(name) != ".go" {
+		return buf.Bytes(), nil
+	}
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated %s is invalid: %v", name, err)
+	}
+	return formatted, nil
+}
+
+// identifierFor converts the specified capability type to an exported Go identifier, e.g. "postgres-ha" to "PostgresHa"
+func identifierFor(capType string) string {
+	ident := strings.Builder{}
+	upper := true
+	for _, r := range capType {
+		switch {
+		case unicode.IsLetter(r) || (unicode.IsDigit(r) && ident.Len() > 0):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			ident.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return ident.String()
+}
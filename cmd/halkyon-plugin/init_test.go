@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const frameworkPseudoVersion = "v0.0.0-20200210150256-1ba4f5bf23c8"
+
+func TestIdentifierFor(t *testing.T) {
+	tests := []struct {
+		capType  string
+		expected string
+	}{
+		{"postgres", "Postgres"},
+		{"postgres-ha", "PostgresHa"},
+		{"my_sql", "MySql"},
+		{"db2", "Db2"},
+		{"9db", "Db"},
+		{"--", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.capType, func(t *testing.T) {
+			if got := identifierFor(tt.capType); got != tt.expected {
+				t.Errorf("identifierFor(%q) = %q, expected %q", tt.capType, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPluginSpecValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    pluginSpec
+		wantErr bool
+	}{
+		{"valid", pluginSpec{Category: "database", Type: "postgres", Versions: []string{"^10", "11"}, FrameworkVersion: "v1.0.0"}, false},
+		{"no versions", pluginSpec{Category: "database", Type: "postgres", FrameworkVersion: "v1.0.0"}, false},
+		{"pseudo-version", pluginSpec{Category: "database", Type: "postgres", FrameworkVersion: frameworkPseudoVersion}, false},
+		{"missing category", pluginSpec{Type: "postgres", FrameworkVersion: "v1.0.0"}, true},
+		{"missing type", pluginSpec{Category: "database", FrameworkVersion: "v1.0.0"}, true},
+		{"invalid type", pluginSpec{Category: "database", Type: "--", FrameworkVersion: "v1.0.0"}, true},
+		{"invalid version", pluginSpec{Category: "database", Type: "postgres", Versions: []string{"foo"}, FrameworkVersion: "v1.0.0"}, true},
+		{"missing framework version", pluginSpec{Category: "database", Type: "postgres"}, true},
+		{"branch framework version", pluginSpec{Category: "database", Type: "postgres", FrameworkVersion: "master"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.spec.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "halkyon-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	spec := pluginSpec{
+		Module:           "example.com/postgres-plugin",
+		Name:             "postgres-plugin",
+		Category:         "database",
+		Type:             "postgres",
+		Versions:         []string{"^10", "11"},
+		FrameworkVersion: frameworkPseudoVersion,
+		APIVersion:       defaultAPIVersion,
+	}
+	if err := generate(dir, spec, false); err != nil {
+		t.Fatal(err)
+	}
+	for name := range pluginTemplates {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be generated: %v", name, err)
+		}
+	}
+	resource, err := ioutil.ReadFile(filepath.Join(dir, "resource.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `Versions: []string{"^10", "11"}`; !strings.Contains(string(resource), expected) {
+		t.Errorf("expected resource.go to contain '%s', got:\n%s", expected, resource)
+	}
+	goMod, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "halkyon.io/operator-framework " + frameworkPseudoVersion; !strings.Contains(string(goMod), expected) {
+		t.Errorf("expected go.mod to contain '%s', got:\n%s", expected, goMod)
+	}
+	typeCheck(t, dir)
+
+	if err := generate(dir, spec, false); err == nil {
+		t.Error("expected generating over existing files to fail")
+	}
+	if err := generate(dir, spec, true); err != nil {
+		t.Errorf("expected forced generation to succeed: %v", err)
+	}
+}
+
+// typeCheck parses and type-checks the Go sources, including tests, generated in the specified directory, resolving their
+// imports against this module and its dependencies using the export data the go command produces for them
+func typeCheck(t *testing.T, dir string) {
+	t.Helper()
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name := range pluginTemplates {
+		if filepath.Ext(name) != ".go" {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.AllErrors)
+		if err != nil {
+			t.Fatalf("couldn't parse generated %s: %v", name, err)
+		}
+		files = append(files, file)
+	}
+	lookup := func(path string) (io.ReadCloser, error) {
+		export, err := exec.Command("go", "list", "-export", "-f", "{{.Export}}", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't find export data for %s: %v", path, err)
+		}
+		return os.Open(strings.TrimSpace(string(export)))
+	}
+	config := types.Config{Importer: importer.ForCompiler(fset, "gc", lookup)}
+	if _, err := config.Check("main", fset, files, nil); err != nil {
+		t.Errorf("generated sources don't type-check: %v", err)
+	}
+}
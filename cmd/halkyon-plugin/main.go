@@ -0,0 +1,57 @@
+/*
+halkyon-plugin provides tooling for capability plugin authors.
+
+Usage:
+
+	halkyon-plugin <command> [flags]
+
+The available commands are:
+
+	init    generates a new capability plugin module
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"init": {description: "generates a new capability plugin module", run: runInit},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: halkyon-plugin <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for name, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s%s\n", name, cmd.description)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'halkyon-plugin <command> -h' for more information about a command.")
+}
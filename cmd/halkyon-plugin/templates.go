@@ -0,0 +1,199 @@
+package main
+
+// pluginTemplates associates the name of each generated file with its template, rendered using a pluginSpec
+var pluginTemplates = map[string]string{
+	"go.mod":           goModTemplate,
+	"main.go":          mainTemplate,
+	"resource.go":      resourceTemplate,
+	"dependent.go":     dependentTemplate,
+	"resource_test.go": resourceTestTemplate,
+	"Makefile":         makefileTemplate,
+	".gitignore":       gitignoreTemplate,
+}
+
+const goModTemplate = `module {{.Module}}
+
+go 1.13
+
+require (
+	halkyon.io/api {{.APIVersion}}
+	halkyon.io/operator-framework {{.FrameworkVersion}}
+	k8s.io/api v0.0.0-20190918195907-bd6ac527cfd2
+	k8s.io/apimachinery v0.17.0
+	k8s.io/client-go v11.0.1-0.20190805182715-88a2adca7e76+incompatible
+	sigs.k8s.io/controller-runtime v0.3.0
+)
+
+replace (
+	k8s.io/api => k8s.io/api v0.0.0-20190805182251-6c9aa3caf3d6 // kubernetes-1.14.5
+	k8s.io/apimachinery => k8s.io/apimachinery v0.0.0-20190404173353-6a84e37a896d // kubernetes-1.14.5
+	k8s.io/client-go => k8s.io/client-go v11.0.1-0.20190805182715-88a2adca7e76+incompatible
+)
+`
+
+const mainTemplate = `package main
+
+import (
+	"halkyon.io/operator-framework/plugins/capability"
+)
+
+func main() {
+	capability.StartPluginServerFor(New{{.Ident}}PluginResource())
+}
+`
+
+const resourceTemplate = `package main
+
+import (
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/plugins/capability"
+)
+
+var _ capability.PluginResource = &{{.Ident}}PluginResource{}
+
+// {{.Ident}}PluginResource provides support for the {{printf "%q" .Type}} type of {{printf "%q" .Category}} capabilities
+type {{.Ident}}PluginResource struct {
+	capability.SimplePluginResourceStem
+}
+
+// New{{.Ident}}PluginResource creates a new {{.Ident}}PluginResource
+func New{{.Ident}}PluginResource() *{{.Ident}}PluginResource {
+	return &{{.Ident}}PluginResource{
+		SimplePluginResourceStem: capability.NewSimplePluginResourceStem({{printf "%q" .Category}}, capability.TypeInfo{
+			Type:     {{printf "%q" .Type}},
+			Versions: []string{ {{- range $i, $v := .Versions}}{{if $i}}, {{end}}{{printf "%q" $v}}{{end -}} },
+		}),
+	}
+}
+
+// GetDependentResourcesWith returns the resources to create, in order, for the specified capability
+func (p *{{.Ident}}PluginResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
+	return []framework.DependentResource{newSecret(owner)}
+}
+
+// CheckValidity returns the validation error messages for the specified capability, if any
+func (p *{{.Ident}}PluginResource) CheckValidity(owner framework.SerializableResource) []string {
+	return nil
+}
+`
+
+const dependentTemplate = `package main
+
+import (
+	framework "halkyon.io/operator-framework"
+)
+
+// secret is a sample dependent, creating a Secret holding the connection information of the capability. Replace it with the
+// resources your capability actually needs.
+type secret struct {
+	owner framework.SerializableResource
+}
+
+var _ framework.NeedsSecret = secret{}
+
+func newSecret(owner framework.SerializableResource) framework.DependentResource {
+	return framework.NewSecret(secret{owner: owner})
+}
+
+func (s secret) GetDataMap() map[string][]byte {
+	return map[string][]byte{
+		"HOST": []byte(s.owner.GetName() + "." + s.owner.GetNamespace()),
+	}
+}
+
+func (s secret) GetSecretName() string {
+	return framework.DefaultSecretNameFrom(s.owner)
+}
+
+func (s secret) Owner() framework.SerializableResource {
+	return s.owner
+}
+`
+
+const resourceTestTemplate = `package main
+
+import (
+	"context"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/operator-framework/plugins/capability"
+	"halkyon.io/operator-framework/plugins/capability/plugintest"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := halkyon.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newCapability(name string) *halkyon.Capability {
+	return &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test"},
+		Spec: halkyon.CapabilitySpec{
+			Category: {{printf "%q" .Category}},
+			Type:     {{printf "%q" .Type}},
+			Version:  {{printf "%q" .SampleVersion}},
+		},
+	}
+}
+
+func TestConformance(t *testing.T) {
+	plugintest.AssertConformance(t, New{{.Ident}}PluginResource(), newScheme(t), newCapability("sample"))
+}
+
+func TestSecret(t *testing.T) {
+	h, err := plugintest.NewHarness(newScheme(t), nil, capability.PluginConfig{}, New{{.Ident}}PluginResource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	owner := newCapability("sample")
+	if err := h.CheckValidity(owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.CreateOrUpdate(owner); err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{}
+	if err := h.Client.Get(context.TODO(), client.ObjectKey{Name: "sample-config", Namespace: "test"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "sample.test"; string(secret.Data["HOST"]) != expected {
+		t.Errorf("expected HOST to be '%s', got '%s'", expected, secret.Data["HOST"])
+	}
+}
+`
+
+const makefileTemplate = `BINARY := bin/{{.Name}}
+
+.PHONY: all build test clean
+
+all: test build
+
+# resolves the dependencies of the generated go.mod and records their checksums
+go.sum: go.mod
+	go mod tidy
+
+build: go.sum
+	go build -o $(BINARY) .
+
+test: go.sum
+	go test ./...
+
+clean:
+	rm -rf bin
+`
+
+const gitignoreTemplate = `/bin/
+`
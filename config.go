@@ -35,6 +35,11 @@ type DependentResourceConfig struct {
 	// but, in some instances, e.g. for Capabilities part of Component's contract, it might be needed to be overridden to be
 	// more precise / specific.
 	TypeName string
+	// EventFilter determines which events on objects of the associated DependentResource's type trigger a reconcile of its
+	// parent Resource, when it's Watched. Since a controller sets up a single watch per dependent type, the EventFilter of the
+	// first DependentResource of a given type causing the watch to be set up applies to all the DependentResources of that
+	// type. Defaults to an empty EventFilter, letting all events through.
+	EventFilter EventFilter
 }
 
 // defaultConfig records the default configuration values for these values that might be omitted.
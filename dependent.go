@@ -35,11 +35,64 @@ type DependentResource interface {
 	GetConfig() DependentResourceConfig
 }
 
+// ContextAwareDependentResource is an optional interface DependentResources can implement to receive the context of the
+// reconcile request they are processed for, so that the calls they make to the cluster (or to plugins) can be cancelled,
+// time-boxed or traced. The framework uses these methods instead of their context-less counterparts when they are available.
+type ContextAwareDependentResource interface {
+	DependentResource
+	// FetchWithContext is the context-aware version of Fetch
+	FetchWithContext(ctx context.Context) (runtime.Object, error)
+	// BuildWithContext is the context-aware version of Build
+	BuildWithContext(ctx context.Context, empty bool) (runtime.Object, error)
+	// UpdateWithContext is the context-aware version of Update
+	UpdateWithContext(ctx context.Context, toUpdate runtime.Object) (bool, runtime.Object, error)
+	// GetConditionWithContext is the context-aware version of GetCondition
+	GetConditionWithContext(ctx context.Context, underlying runtime.Object, err error) *v1beta1.DependentCondition
+}
+
+// WithContext adapts the specified DependentResource to the ContextAwareDependentResource interface. DependentResources already
+// implementing it are returned as-is while the context-less methods of the other ones are called, the context being ignored.
+func WithContext(dependent DependentResource) ContextAwareDependentResource {
+	if aware, ok := dependent.(ContextAwareDependentResource); ok {
+		return aware
+	}
+	return contextAdapter{DependentResource: dependent}
+}
+
+// contextAdapter adapts legacy DependentResources to the ContextAwareDependentResource interface
+type contextAdapter struct {
+	DependentResource
+}
+
+func (c contextAdapter) FetchWithContext(_ context.Context) (runtime.Object, error) {
+	return c.Fetch()
+}
+
+func (c contextAdapter) BuildWithContext(_ context.Context, empty bool) (runtime.Object, error) {
+	return c.Build(empty)
+}
+
+func (c contextAdapter) UpdateWithContext(_ context.Context, toUpdate runtime.Object) (bool, runtime.Object, error) {
+	return c.Update(toUpdate)
+}
+
+func (c contextAdapter) GetConditionWithContext(_ context.Context, underlying runtime.Object, err error) *v1beta1.DependentCondition {
+	return c.GetCondition(underlying, err)
+}
+
 // CreateOrUpdate provides a generic implementation of the logic to create or update a DependentResource. A DependentResource is
 // created if its associated configuration allows it and if a NotFound error is thrown when attempting to fetch it: its Build
 // method is called and the resulting object is sent to the cluster to be created. Otherwise, if the resource is indeed fetched,
 // it will be updated according to its Update method (if its configuration allows for it) and save to the cluster.
 func CreateOrUpdate(r DependentResource) error {
+	return CreateOrUpdateWithContext(context.TODO(), r)
+}
+
+// CreateOrUpdateWithContext is the context-aware version of CreateOrUpdate, the specified context being passed to the
+// DependentResource, if it implements ContextAwareDependentResource, and used for all calls to the cluster.
+func CreateOrUpdateWithContext(ctx context.Context, dependent DependentResource) error {
+	r := WithContext(dependent)
+	helper := HelperFor(dependent)
 	// if the resource specifies that it shouldn't be created, exit fast
 	config := r.GetConfig()
 	if !config.Created && !config.Updated {
@@ -47,12 +100,12 @@ func CreateOrUpdate(r DependentResource) error {
 	}
 
 	kind := config.TypeName
-	object, err := r.Fetch()
+	object, err := r.FetchWithContext(ctx)
 	logger := LoggerFor(r.Owner())
 	if err != nil {
 		if config.Created && errors.IsNotFound(err) {
 			// create the object
-			obj, errBuildObject := r.Build(false)
+			obj, errBuildObject := r.BuildWithContext(ctx, false)
 			if errBuildObject != nil {
 				return errBuildObject
 			}
@@ -61,14 +114,14 @@ func CreateOrUpdate(r DependentResource) error {
 			if config.Owned {
 				// in most instances, resourceDefinedOwner == owner but some resources might want to return a different one
 				resourceDefinedOwner := r.Owner()
-				if e := controllerutil.SetControllerReference(resourceDefinedOwner, obj.(v1.Object), Helper.Scheme); e != nil {
+				if e := controllerutil.SetControllerReference(resourceDefinedOwner, obj.(v1.Object), helper.Scheme); e != nil {
 					logger.Error(err, "Failed to set owner", "owner", resourceDefinedOwner, "resource", r.Name())
 					return e
 				}
 			}
 
 			alreadyExists := false
-			if err = Helper.Client.Create(context.TODO(), obj); err != nil {
+			if err = helper.Client.Create(ctx, obj); err != nil {
 				// ignore error if it's to state that obj already exists
 				alreadyExists = errors.IsAlreadyExists(err)
 				if !alreadyExists {
@@ -86,12 +139,12 @@ func CreateOrUpdate(r DependentResource) error {
 	} else {
 		if config.Updated {
 			// if the resource defined an updater, use it to try to update the resource
-			updated, toUpdate, err := r.Update(object)
+			updated, toUpdate, err := r.UpdateWithContext(ctx, object)
 			if err != nil {
 				return err
 			}
 			if updated {
-				if err = Helper.Client.Update(context.TODO(), toUpdate); err != nil {
+				if err = helper.Client.Update(ctx, toUpdate); err != nil {
 					logger.Error(err, "Failed to update", "kind", kind)
 				}
 				logger.Info("Updated successfully", "kind", kind, "name", object.(v1.Object).GetName())
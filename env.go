@@ -1,55 +1,70 @@
 package framework
 
 import (
-	"halkyon.io/operator-framework/util"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	"strings"
 )
 
-var (
-	onOpenShift      *bool
-	openShiftVersion int
-)
+// openShiftInfo records whether the cluster a K8SHelper targets is running OpenShift and, if so, which version
+type openShiftInfo struct {
+	version int
+}
 
+// OpenShiftVersion returns the major version of OpenShift the cluster targeted by the default Helper is running, 0 if it's not
+// running OpenShift
 func OpenShiftVersion() int {
-	return openShiftVersion
+	return Helper.OpenShiftVersion()
 }
 
+// IsTargetClusterRunningOpenShift determines whether the cluster targeted by the default Helper is running OpenShift
 func IsTargetClusterRunningOpenShift() bool {
-	return *onOpenShift
+	return Helper.IsTargetClusterRunningOpenShift()
 }
 
-func checkIfOpenShift(config *rest.Config) {
-	if onOpenShift == nil {
-		discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
-		if err != nil {
-			panic(err)
-		}
-		apiList, err := discoveryClient.ServerGroups()
-		if err != nil {
-			panic(err)
-		}
-		apiGroups := apiList.Groups
-		const openShiftGroupSuffix = ".openshift.io"
-		const openShift4GroupName = "config" + openShiftGroupSuffix
-		for _, group := range apiGroups {
-			if strings.HasSuffix(group.Name, openShiftGroupSuffix) {
-				if onOpenShift == nil {
-					onOpenShift = util.NewTrue()
-					openShiftVersion = 3
-				}
-				if group.Name == openShift4GroupName {
-					openShiftVersion = 4
-					break
-				}
-			}
-		}
+// OpenShiftVersion returns the major version of OpenShift the cluster targeted by this K8SHelper is running, 0 if it's not
+// running OpenShift or if it hasn't been determined
+func (rh K8SHelper) OpenShiftVersion() int {
+	if rh.openShift == nil {
+		return 0
+	}
+	return rh.openShift.version
+}
 
-		if onOpenShift == nil {
-			// we didn't find any api group with the openshift.io suffix, so we're not on OpenShift!
-			onOpenShift = util.NewFalse()
-			openShiftVersion = 0
+// IsTargetClusterRunningOpenShift determines whether the cluster targeted by this K8SHelper is running OpenShift
+func (rh K8SHelper) IsTargetClusterRunningOpenShift() bool {
+	return rh.OpenShiftVersion() > 0
+}
+
+// SetOpenShiftVersion records the major version of OpenShift the cluster targeted by this K8SHelper is running, 0 meaning that
+// it's not running OpenShift. This bypasses the detection performed when the K8SHelper is created from a Manager, which is
+// typically useful when testing.
+func (rh *K8SHelper) SetOpenShiftVersion(version int) {
+	rh.openShift = &openShiftInfo{version: version}
+}
+
+// detectOpenShiftVersion queries the cluster associated with the specified configuration to determine which major version of
+// OpenShift it is running, if any
+func detectOpenShiftVersion(config *rest.Config) (int, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return 0, err
+	}
+	apiList, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return 0, err
+	}
+	const openShiftGroupSuffix = ".openshift.io"
+	const openShift4GroupName = "config" + openShiftGroupSuffix
+	version := 0
+	for _, group := range apiList.Groups {
+		if strings.HasSuffix(group.Name, openShiftGroupSuffix) {
+			version = 3
+			if group.Name == openShift4GroupName {
+				return 4, nil
+			}
 		}
 	}
+	// if we didn't find any api group with the openshift.io suffix, we're not on OpenShift!
+	return version, nil
 }
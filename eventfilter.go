@@ -0,0 +1,92 @@
+package framework
+
+import (
+	"fmt"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// EventFilter configures which events on watched objects trigger a reconcile. The zero value doesn't filter any event. Apart
+// from Custom, an EventFilter can be serialized so that plugins can configure the EventFilter of their dependents.
+type EventFilter struct {
+	// GenerationChanged filters out update events that don't change the generation of the object, e.g. status or metadata
+	// only changes. Note that the generation of some types, e.g. Secrets or ConfigMaps, never changes.
+	GenerationChanged bool
+	// AnnotationsChanged lets update events changing the annotations of the object through. When combined with
+	// GenerationChanged, update events go through if either the generation or the annotations changed, otherwise only updates
+	// changing the annotations go through.
+	AnnotationsChanged bool
+	// LabelSelector, if set, filters out events on objects whose labels don't match this selector, using the usual label
+	// selector syntax, e.g. "app=frontend,tier!=cache"
+	LabelSelector string
+	// Custom, if set, is called with the object concerned by each event and, for update events, its previous version, the event
+	// being filtered out if it returns false. Custom is not serialized.
+	Custom func(object, previous v1.Object) bool `json:"-"`
+}
+
+// IsEmpty determines whether this EventFilter lets all events through
+func (f EventFilter) IsEmpty() bool {
+	return !f.GenerationChanged && !f.AnnotationsChanged && len(f.LabelSelector) == 0 && f.Custom == nil
+}
+
+// Predicates returns the predicates implementing this EventFilter, to be passed when setting up a watch, or an error if its
+// LabelSelector is invalid
+func (f EventFilter) Predicates() ([]predicate.Predicate, error) {
+	if f.IsEmpty() {
+		return nil, nil
+	}
+	selector := labels.Everything()
+	if len(f.LabelSelector) > 0 {
+		var err error
+		if selector, err = labels.Parse(f.LabelSelector); err != nil {
+			return nil, fmt.Errorf("invalid label selector '%s': %w", f.LabelSelector, err)
+		}
+	}
+	matches := func(object v1.Object) bool {
+		return object != nil && selector.Matches(labels.Set(object.GetLabels()))
+	}
+	accepts := func(object, previous v1.Object) bool {
+		return f.Custom == nil || f.Custom(object, previous)
+	}
+	return []predicate.Predicate{predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return matches(e.Meta) && accepts(e.Meta, nil)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return matches(e.Meta) && accepts(e.Meta, nil)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.MetaOld == nil || e.MetaNew == nil || !f.changed(e.MetaNew, e.MetaOld) {
+				return false
+			}
+			// let updates through if either version matches the selector so that objects stopping to match are processed
+			return (matches(e.MetaNew) || matches(e.MetaOld)) && accepts(e.MetaNew, e.MetaOld)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return matches(e.Meta) && accepts(e.Meta, nil)
+		},
+	}}, nil
+}
+
+// changed determines whether the changes from the previous to the current version of an object are relevant to this EventFilter
+func (f EventFilter) changed(object, previous v1.Object) bool {
+	if !f.GenerationChanged && !f.AnnotationsChanged {
+		return true
+	}
+	if f.GenerationChanged && object.GetGeneration() != previous.GetGeneration() {
+		return true
+	}
+	return f.AnnotationsChanged && !reflect.DeepEqual(object.GetAnnotations(), previous.GetAnnotations())
+}
+
+// EventFiltered is an optional interface Resources can implement to filter the events on their own type triggering their
+// reconciliation, e.g. using GenerationChanged to avoid being reconciled again when their status is updated. Finalizable
+// Resources need to let updates setting their deletion timestamp through, which GenerationChanged does since Kubernetes
+// increments the generation of objects with finalizers when they're marked for deletion.
+type EventFiltered interface {
+	// GetEventFilter returns the EventFilter to apply to the events on this Resource's type
+	GetEventFilter() EventFilter
+}
@@ -0,0 +1,168 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/gob"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+)
+
+func TestEventFilter(t *testing.T) {
+	pod := func(generation int64, labels, annotations map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "pod", Generation: generation, Labels: labels, Annotations: annotations}}
+	}
+	frontend := map[string]string{"app": "frontend"}
+	backend := map[string]string{"app": "backend"}
+	restarted := map[string]string{"restartedAt": "now"}
+	update := func(old, new *corev1.Pod) event.UpdateEvent {
+		return event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: new, ObjectNew: new}
+	}
+
+	tests := []struct {
+		name     string
+		filter   EventFilter
+		create   *corev1.Pod
+		update   event.UpdateEvent
+		expected [2]bool
+	}{
+		{
+			name:     "empty",
+			create:   pod(1, nil, nil),
+			update:   update(pod(1, nil, nil), pod(1, nil, nil)),
+			expected: [2]bool{true, true},
+		},
+		{
+			name:     "generation unchanged",
+			filter:   EventFilter{GenerationChanged: true},
+			create:   pod(1, nil, nil),
+			update:   update(pod(1, nil, nil), pod(1, frontend, restarted)),
+			expected: [2]bool{true, false},
+		},
+		{
+			name:     "generation changed",
+			filter:   EventFilter{GenerationChanged: true},
+			create:   pod(1, nil, nil),
+			update:   update(pod(1, nil, nil), pod(2, nil, nil)),
+			expected: [2]bool{true, true},
+		},
+		{
+			name:     "annotations changed",
+			filter:   EventFilter{GenerationChanged: true, AnnotationsChanged: true},
+			create:   pod(1, nil, nil),
+			update:   update(pod(1, nil, nil), pod(1, nil, restarted)),
+			expected: [2]bool{true, true},
+		},
+		{
+			name:     "annotations unchanged",
+			filter:   EventFilter{AnnotationsChanged: true},
+			create:   pod(1, nil, nil),
+			update:   update(pod(1, nil, restarted), pod(2, nil, restarted)),
+			expected: [2]bool{true, false},
+		},
+		{
+			name:     "labels not matching",
+			filter:   EventFilter{LabelSelector: "app=frontend"},
+			create:   pod(1, backend, nil),
+			update:   update(pod(1, backend, nil), pod(2, backend, nil)),
+			expected: [2]bool{false, false},
+		},
+		{
+			name:     "labels not matching anymore",
+			filter:   EventFilter{LabelSelector: "app=frontend"},
+			create:   pod(1, frontend, nil),
+			update:   update(pod(1, frontend, nil), pod(1, backend, nil)),
+			expected: [2]bool{true, true},
+		},
+		{
+			name: "custom",
+			filter: EventFilter{Custom: func(object, previous v1.Object) bool {
+				return previous == nil
+			}},
+			create:   pod(1, nil, nil),
+			update:   update(pod(1, nil, nil), pod(2, nil, nil)),
+			expected: [2]bool{true, false},
+		},
+		{
+			name: "custom generation increased",
+			filter: EventFilter{Custom: func(object, previous v1.Object) bool {
+				return previous == nil || object.GetGeneration() > previous.GetGeneration()
+			}},
+			create:   pod(2, nil, nil),
+			update:   update(pod(2, nil, nil), pod(1, nil, nil)),
+			expected: [2]bool{true, false},
+		},
+		{
+			name: "custom label added",
+			filter: EventFilter{LabelSelector: "app", Custom: func(object, previous v1.Object) bool {
+				return previous == nil || len(object.GetLabels()) > len(previous.GetLabels())
+			}},
+			create:   pod(1, frontend, nil),
+			update:   update(pod(1, frontend, nil), pod(1, nil, nil)),
+			expected: [2]bool{true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			predicates, err := tt.filter.Predicates()
+			if err != nil {
+				t.Fatal(err)
+			}
+			create, update := true, true
+			for _, p := range predicates {
+				create = create && p.Create(event.CreateEvent{Meta: tt.create, Object: tt.create})
+				update = update && p.Update(tt.update)
+			}
+			if actual := [2]bool{create, update}; actual != tt.expected {
+				t.Errorf("expected create and update events to be let through: %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEventFilterInvalidSelector(t *testing.T) {
+	if _, err := (EventFilter{LabelSelector: "app in (frontend"}).Predicates(); err == nil {
+		t.Error("expected an invalid label selector to be rejected")
+	}
+}
+
+func TestEventFilterIsSerializable(t *testing.T) {
+	config := NewConfig(corev1.SchemeGroupVersion.WithKind("Secret"))
+	config.EventFilter = EventFilter{GenerationChanged: true, LabelSelector: "app=frontend", Custom: func(v1.Object, v1.Object) bool {
+		return false
+	}}
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(config); err != nil {
+		t.Fatal(err)
+	}
+	decoded := DependentResourceConfig{}
+	if err := gob.NewDecoder(buffer).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if filter := decoded.EventFilter; !filter.GenerationChanged || filter.LabelSelector != "app=frontend" || filter.Custom != nil {
+		t.Errorf("unexpected decoded EventFilter: %+v", filter)
+	}
+}
+
+func TestEventFilterCallsCustomOncePerUpdate(t *testing.T) {
+	old := &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "pod", Generation: 1}}
+	updated := &corev1.Pod{ObjectMeta: v1.ObjectMeta{Name: "pod", Generation: 2}}
+	calls := 0
+	predicates, err := EventFilter{Custom: func(object, previous v1.Object) bool {
+		calls++
+		if object != updated || previous != old {
+			t.Errorf("expected Custom to be called with the new then old versions, got %v and %v", object, previous)
+		}
+		return false
+	}}.Predicates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if predicates[0].Update(event.UpdateEvent{MetaOld: old, ObjectOld: old, MetaNew: updated, ObjectNew: updated}) {
+		t.Error("expected update to be filtered out")
+	}
+	if calls != 1 {
+		t.Errorf("expected Custom to be called once, got %d calls", calls)
+	}
+}
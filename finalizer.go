@@ -0,0 +1,65 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/operator-framework/util"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Finalizable is an optional interface that Resources can implement when they need to perform clean-up operations while they
+// still exist on the cluster, e.g. to release external resources. The framework adds the finalizer named by GetFinalizerName to
+// such Resources so that, when they are deleted, Finalize gets called with their last known state. The finalizer is only
+// removed, thus letting the cluster actually delete the Resource, once Finalize succeeds.
+type Finalizable interface {
+	// GetFinalizerName returns the name of the finalizer the framework manages on behalf of this Resource
+	GetFinalizerName() string
+	// Finalize performs the clean-up operations needed before this Resource is deleted. Returning an error prevents the deletion
+	// of the Resource, Finalize being called again when the Resource is requeued.
+	Finalize() error
+}
+
+// handleFinalization adds or processes the finalizer of the specified Finalizable Resource as needed, returning whether the
+// reconcile loop is done with the Resource and, if so, the result of the reconcile
+func (b *GenericReconciler) handleFinalization(ctx context.Context, resource Resource, finalizable Finalizable) (done bool, result reconcile.Result, err error) {
+	object := resource.GetUnderlyingAPIResource()
+	finalizer := finalizable.GetFinalizerName()
+	finalizers := object.GetFinalizers()
+	hasFinalizer := util.Index(finalizers, finalizer) >= 0
+	typeName := util.GetObjectName(object)
+
+	if object.GetDeletionTimestamp() == nil {
+		if hasFinalizer {
+			return false, reconcile.Result{}, nil
+		}
+		// add finalizer and requeue explicitly since the update, only changing metadata, might be filtered out, see EventFilter
+		object.SetFinalizers(append(finalizers, finalizer))
+		if err := b.GetHelper().Client.Update(ctx, object); err != nil {
+			b.logger().Error(err, fmt.Sprintf("failed to add finalizer to '%s' %s", resource.GetName(), typeName))
+			return true, reconcile.Result{}, err
+		}
+		return true, reconcile.Result{Requeue: true}, nil
+	}
+
+	// resource is being deleted
+	if !hasFinalizer {
+		return true, reconcile.Result{}, nil
+	}
+	b.logger().Info("'"+resource.GetName()+"' "+typeName+" is being deleted. Running finalization.", "finalizer", finalizer)
+	if err := finalizable.Finalize(); err != nil {
+		_ = UpdateStatusIfNeededWithContext(ctx, resource, fmt.Errorf("finalization error: %v", err))
+		return true, reconcile.Result{}, err
+	}
+	remaining := make([]string, 0, len(finalizers))
+	for _, f := range finalizers {
+		if f != finalizer {
+			remaining = append(remaining, f)
+		}
+	}
+	object.SetFinalizers(remaining)
+	if err := b.GetHelper().Client.Update(ctx, object); err != nil {
+		b.logger().Error(err, fmt.Sprintf("failed to remove finalizer from '%s' %s", resource.GetName(), typeName))
+		return true, reconcile.Result{}, err
+	}
+	return true, reconcile.Result{}, nil
+}
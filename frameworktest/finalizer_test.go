@@ -0,0 +1,127 @@
+package frameworktest
+
+import (
+	"fmt"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/util"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"testing"
+	"time"
+)
+
+const testFinalizer = "test.halkyon.io/finalizer"
+
+// finalizations records the calls to Finalize of finalizableResources and the error they return
+type finalizations struct {
+	calls int
+	err   error
+}
+
+// finalizableResource is a capabilityResource needing to be finalized
+type finalizableResource struct {
+	*capabilityResource
+	finalizations *finalizations
+}
+
+func (f finalizableResource) GetFinalizerName() string {
+	return testFinalizer
+}
+
+func (f finalizableResource) Finalize() error {
+	f.finalizations.calls++
+	return f.finalizations.err
+}
+
+func (f finalizableResource) NewEmpty() framework.Resource {
+	return finalizableResource{capabilityResource: newCapabilityResource(), finalizations: f.finalizations}
+}
+
+func newFinalizableHarness(t *testing.T, finalizations *finalizations, objects ...runtime.Object) *Harness {
+	prototype := finalizableResource{capabilityResource: newCapabilityResource(), finalizations: finalizations}
+	return NewHarness(newScheme(t), prototype, Config{Objects: objects})
+}
+
+func deletedCapability(name string) runtime.Object {
+	capability := newCapability(name)
+	capability.Finalizers = []string{testFinalizer}
+	capability.DeletionTimestamp = &v1.Time{Time: time.Now()}
+	return capability
+}
+
+func finalizersOf(t *testing.T, h *Harness, name string) []string {
+	t.Helper()
+	resource, err := h.Get(name, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resource.GetFinalizers()
+}
+
+func TestFinalizerIsAdded(t *testing.T) {
+	finalizations := &finalizations{}
+	h := newFinalizableHarness(t, finalizations, newCapability("db"))
+
+	result, err := h.Reconcile("db", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Requeue {
+		t.Error("expected resource to be requeued once its finalizer is added")
+	}
+	if finalizers := finalizersOf(t, h, "db"); util.Index(finalizers, testFinalizer) < 0 {
+		t.Errorf("expected finalizer '%s' to be added, got %v", testFinalizer, finalizers)
+	}
+	h.AssertNoDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "db-config", "test")
+
+	// the requeued reconcile proceeds with the resource
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	h.AssertDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "db-config", "test")
+	if finalizations.calls != 0 {
+		t.Errorf("didn't expect resource to be finalized, got %d calls", finalizations.calls)
+	}
+}
+
+func TestFinalizerIsRemovedOnceFinalized(t *testing.T) {
+	finalizations := &finalizations{}
+	h := newFinalizableHarness(t, finalizations, deletedCapability("db"))
+
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if finalizations.calls != 1 {
+		t.Errorf("expected resource to be finalized once, got %d calls", finalizations.calls)
+	}
+	if finalizers := finalizersOf(t, h, "db"); len(finalizers) != 0 {
+		t.Errorf("expected finalizer to be removed, got %v", finalizers)
+	}
+	h.AssertNoDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "db-config", "test")
+}
+
+func TestFinalizerIsKeptWhenFinalizationFails(t *testing.T) {
+	finalizations := &finalizations{err: fmt.Errorf("couldn't drop database")}
+	h := newFinalizableHarness(t, finalizations, deletedCapability("db"))
+
+	if _, err := h.Reconcile("db", "test"); err == nil {
+		t.Fatal("expected reconcile to fail")
+	}
+	if finalizations.calls != 1 {
+		t.Errorf("expected resource to be finalized once, got %d calls", finalizations.calls)
+	}
+	if finalizers := finalizersOf(t, h, "db"); util.Index(finalizers, testFinalizer) < 0 {
+		t.Errorf("expected finalizer '%s' to be kept, got %v", testFinalizer, finalizers)
+	}
+	h.AssertStatusReason(t, "db", "test", "Failed")
+
+	// finalization is attempted again on the next reconcile
+	finalizations.err = nil
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if finalizers := finalizersOf(t, h, "db"); len(finalizers) != 0 {
+		t.Errorf("expected finalizer to be removed, got %v", finalizers)
+	}
+}
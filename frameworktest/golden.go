@@ -0,0 +1,177 @@
+package frameworktest
+
+import (
+	"bytes"
+	"fmt"
+	framework "halkyon.io/operator-framework"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// UpdateGoldenEnvVar is the environment variable which, when set to a true value, makes golden file assertions update the golden
+// files instead of comparing against them, e.g.:
+//
+//	HALKYON_UPDATE_GOLDEN=true go test ./...
+const UpdateGoldenEnvVar = "HALKYON_UPDATE_GOLDEN"
+
+// updatingGolden determines whether golden files should be updated, as requested using the UpdateGoldenEnvVar
+func updatingGolden() bool {
+	update, _ := strconv.ParseBool(os.Getenv(UpdateGoldenEnvVar))
+	return update
+}
+
+// SnapshotResource initializes the dependents of the specified Resource and returns a snapshot of the objects they build, see
+// Snapshot
+func SnapshotResource(resource framework.Resource) ([]byte, error) {
+	dependents, err := resource.InitDependentResources()
+	if err != nil {
+		return nil, err
+	}
+	return Snapshot(dependents...)
+}
+
+// Snapshot serializes the objects the specified DependentResources build, in order, to a deterministic multi-document YAML
+// representation, suitable to be compared against golden files. Null and empty values are omitted.
+func Snapshot(dependents ...framework.DependentResource) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for i, dependent := range dependents {
+		built, err := dependent.Build(false)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't build '%s' %s: %v", dependent.Name(), dependent.GetConfig().TypeName, err)
+		}
+		object, err := toUnstructuredMap(built)
+		if err != nil {
+			return nil, err
+		}
+		// make sure the type information is present even if the built object doesn't record it
+		gvk := dependent.GetConfig().GroupVersionKind
+		object["apiVersion"], object["kind"] = gvk.GroupVersion().String(), gvk.Kind
+		document, err := yaml.Marshal(prune(object))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(document)
+	}
+	return buf.Bytes(), nil
+}
+
+// AssertResourceGolden checks that the snapshot of the objects built by the dependents of the specified Resource matches the
+// content of the golden file at the given path, see AssertGolden
+func AssertResourceGolden(t *testing.T, resource framework.Resource, path string) {
+	t.Helper()
+	snapshot, err := SnapshotResource(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertGolden(t, path, snapshot)
+}
+
+// AssertDependentsGolden checks that the snapshot of the objects built by the specified DependentResources, e.g. as returned by
+// a plugin, matches the content of the golden file at the given path, see AssertGolden
+func AssertDependentsGolden(t *testing.T, path string, dependents ...framework.DependentResource) {
+	t.Helper()
+	snapshot, err := Snapshot(dependents...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertGolden(t, path, snapshot)
+}
+
+// AssertGolden checks that the specified content matches the content of the golden file at the given path, reporting the
+// differing lines otherwise. The golden file is written instead, creating it and its parent directories if needed, when the
+// UpdateGoldenEnvVar environment variable is set to a true value.
+func AssertGolden(t *testing.T, path string, actual []byte) {
+	t.Helper()
+	if updatingGolden() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("couldn't read golden file, run tests with %s=true to create it: %v", UpdateGoldenEnvVar, err)
+	}
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("content doesn't match golden file '%s', run tests with %s=true to update it if the change is expected:\n%s", path, UpdateGoldenEnvVar, lineDiff(string(expected), string(actual)))
+	}
+}
+
+func toUnstructuredMap(object runtime.Object) (map[string]interface{}, error) {
+	if u, ok := object.(*unstructured.Unstructured); ok {
+		return u.DeepCopy().Object, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+}
+
+// prune recursively removes the null values and empty objects from the specified map, returning it
+func prune(object map[string]interface{}) map[string]interface{} {
+	for key, value := range object {
+		switch v := value.(type) {
+		case nil:
+			delete(object, key)
+		case map[string]interface{}:
+			if len(prune(v)) == 0 {
+				delete(object, key)
+			}
+		case []interface{}:
+			for _, element := range v {
+				if m, ok := element.(map[string]interface{}); ok {
+					prune(m)
+				}
+			}
+		}
+	}
+	return object
+}
+
+// lineDiff lists the lines which need to be removed from (-) or added to (+) the expected text to obtain the actual one, along
+// with their line number in the respective texts
+func lineDiff(expected, actual string) string {
+	e, a := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of e[i:] and a[j:]
+	lcs := make([][]int, len(e)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(a)+1)
+	}
+	for i := len(e) - 1; i >= 0; i-- {
+		for j := len(a) - 1; j >= 0; j-- {
+			if e[i] == a[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	buf := &strings.Builder{}
+	i, j := 0, 0
+	for i < len(e) || j < len(a) {
+		switch {
+		case i < len(e) && j < len(a) && e[i] == a[j]:
+			i++
+			j++
+		case j < len(a) && (i == len(e) || lcs[i][j+1] >= lcs[i+1][j]):
+			fmt.Fprintf(buf, "%4d + %s\n", j+1, a[j])
+			j++
+		default:
+			fmt.Fprintf(buf, "%4d - %s\n", i+1, e[i])
+			i++
+		}
+	}
+	return buf.String()
+}
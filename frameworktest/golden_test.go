@@ -0,0 +1,76 @@
+package frameworktest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssertResourceGolden(t *testing.T) {
+	tests := []struct {
+		name             string
+		openShiftVersion int
+	}{
+		{"kubernetes", 0},
+		{"openshift", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHarness(newScheme(t), newCapabilityResource(), Config{OpenShiftVersion: tt.openShiftVersion})
+			resource := newCapabilityResource()
+			resource.Capability = newCapability("db")
+			resource.SetHelper(h.Helper)
+			AssertResourceGolden(t, resource, "testdata/golden/"+tt.name+".yaml")
+		})
+	}
+}
+
+func TestAssertGoldenUpdatesGoldenFilesWhenRequested(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(UpdateGoldenEnvVar)
+	if err := os.Setenv(UpdateGoldenEnvVar, "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "golden", "snapshot.yaml")
+	AssertGolden(t, path, []byte("kind: Secret\n"))
+	written, err := ioutil.ReadFile(path)
+	if err != nil || string(written) != "kind: Secret\n" {
+		t.Fatalf("expected golden file to be written, got '%s' (%v)", written, err)
+	}
+
+	for value, update := range map[string]bool{"1": true, "false": false, "": false, "yes please": false} {
+		if err := os.Setenv(UpdateGoldenEnvVar, value); err != nil {
+			t.Fatal(err)
+		}
+		if updatingGolden() != update {
+			t.Errorf("expected golden files to be updated with %s='%s': %v", UpdateGoldenEnvVar, value, update)
+		}
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		diff     string
+	}{
+		{"identical", "a\nb", "a\nb", ""},
+		{"changed line", "a\nb\nc", "a\nx\nc", "   2 + x\n   2 - b\n"},
+		{"added line", "a\nc", "a\nb\nc", "   2 + b\n"},
+		{"removed line", "a\nb\nc", "a\nc", "   2 - b\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := lineDiff(tt.expected, tt.actual); diff != tt.diff {
+				t.Errorf("lineDiff() = %q, expected %q", diff, tt.diff)
+			}
+		})
+	}
+}
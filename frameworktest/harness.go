@@ -0,0 +1,194 @@
+/*
+Package frameworktest provides the infrastructure to test Resources, and their DependentResources, by reconciling them against a
+fake cluster, without needing a real one.
+*/
+package frameworktest
+
+import (
+	"fmt"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+	"testing"
+)
+
+// Config configures the fake cluster backing a Harness
+type Config struct {
+	// Objects lists the objects the fake cluster is initialized with
+	Objects []runtime.Object
+	// OpenShiftVersion is the major version of OpenShift the fake cluster simulates, defaults to 0, i.e. a vanilla Kubernetes
+	// cluster
+	OpenShiftVersion int
+	// EventsBufferSize is the number of events the Harness can record between two calls to Events, defaults to 100
+	EventsBufferSize int
+	// Options configures the Harness' reconciler, see framework.NewConfiguredGenericReconciler
+	Options framework.ControllerOptions
+}
+
+// Harness reconciles Resources of a given type against a fake cluster, exactly as the operator would, and provides assertions
+// on the resulting cluster state. The Harness doesn't use the framework's default Helper so that tests can run in parallel.
+type Harness struct {
+	// Client is the fake Kubernetes client backing the harness
+	Client client.Client
+	// Scheme is the scheme used by the fake Kubernetes client
+	Scheme *runtime.Scheme
+	// Helper is the K8SHelper the reconciled Resources use
+	Helper *framework.K8SHelper
+	// Recorder records the events emitted while reconciling
+	Recorder *record.FakeRecorder
+	// Reconciler is the GenericReconciler reconciling the Resources
+	Reconciler *framework.GenericReconciler
+
+	prototype framework.Resource
+	events    []string
+}
+
+// NewHarness creates a new Harness reconciling Resources represented by the specified prototype, see
+// framework.NewGenericReconciler. The fake cluster uses the given scheme, which needs to know about the Resources' API type and
+// their dependents' types, and is configured using the specified Config.
+func NewHarness(scheme *runtime.Scheme, prototype framework.Resource, config Config) *Harness {
+	if config.EventsBufferSize <= 0 {
+		config.EventsBufferSize = 100
+	}
+	fakeClient := fake.NewFakeClientWithScheme(scheme, config.Objects...)
+	recorder := record.NewFakeRecorder(config.EventsBufferSize)
+	helper := &framework.K8SHelper{Client: fakeClient, Scheme: scheme, Recorder: recorder}
+	helper.SetOpenShiftVersion(config.OpenShiftVersion)
+	reconciler := framework.NewConfiguredGenericReconciler(prototype, config.Options)
+	reconciler.SetHelper(helper)
+	return &Harness{
+		Client:     fakeClient,
+		Scheme:     scheme,
+		Helper:     helper,
+		Recorder:   recorder,
+		Reconciler: reconciler,
+		prototype:  prototype,
+	}
+}
+
+// Reconcile reconciles the Resource with the specified name and namespace once
+func (h *Harness) Reconcile(name, namespace string) (reconcile.Result, error) {
+	return h.Reconciler.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}})
+}
+
+// ReconcileN reconciles the Resource with the specified name and namespace the given number of times, as would happen when the
+// Resource is requeued or its dependents change, stopping at the first error. The result of the last reconcile is returned.
+func (h *Harness) ReconcileN(name, namespace string, times int) (result reconcile.Result, err error) {
+	for i := 0; i < times; i++ {
+		if result, err = h.Reconcile(name, namespace); err != nil {
+			return result, fmt.Errorf("reconcile #%d failed: %w", i+1, err)
+		}
+	}
+	return result, nil
+}
+
+// Get retrieves the current state of the Resource with the specified name and namespace from the fake cluster. The Resource
+// uses the Harness' Helper if it's HelperAware.
+func (h *Harness) Get(name, namespace string) (framework.Resource, error) {
+	resource := h.prototype.NewEmpty()
+	if aware, ok := resource.(framework.HelperAware); ok {
+		aware.SetHelper(h.Helper)
+	}
+	if _, err := h.Helper.Fetch(name, namespace, resource.GetUnderlyingAPIResource()); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// GetDependent retrieves the object with the specified GroupVersionKind, name and namespace from the fake cluster. The object
+// is typed if its type is known to the scheme, unstructured otherwise.
+func (h *Harness) GetDependent(gvk schema.GroupVersionKind, name, namespace string) (runtime.Object, error) {
+	into, err := h.Scheme.New(gvk)
+	if err != nil {
+		into = framework.CreateEmptyUnstructured(gvk)
+	}
+	return h.Helper.Fetch(name, namespace, into)
+}
+
+// Events returns the events recorded since the Harness was created, formatted as "<type> <reason> <message>"
+func (h *Harness) Events() []string {
+	for {
+		select {
+		case event := <-h.Recorder.Events:
+			h.events = append(h.events, event)
+		default:
+			return h.events
+		}
+	}
+}
+
+// AssertDependent checks that the object with the specified GroupVersionKind, name and namespace exists on the fake cluster,
+// returning it if it does
+func (h *Harness) AssertDependent(t *testing.T, gvk schema.GroupVersionKind, name, namespace string) runtime.Object {
+	t.Helper()
+	object, err := h.GetDependent(gvk, name, namespace)
+	if err != nil {
+		t.Errorf("expected '%s' %s to exist in namespace '%s': %v", name, gvk.Kind, namespace, err)
+		return nil
+	}
+	return object
+}
+
+// AssertNoDependent checks that no object with the specified GroupVersionKind, name and namespace exists on the fake cluster
+func (h *Harness) AssertNoDependent(t *testing.T, gvk schema.GroupVersionKind, name, namespace string) {
+	t.Helper()
+	if _, err := h.GetDependent(gvk, name, namespace); !errors.IsNotFound(err) {
+		t.Errorf("expected '%s' %s not to exist in namespace '%s', got: %v", name, gvk.Kind, namespace, err)
+	}
+}
+
+// AssertStatusReason checks that the status of the Resource with the specified name and namespace has the given reason
+func (h *Harness) AssertStatusReason(t *testing.T, name, namespace, reason string) {
+	t.Helper()
+	resource, err := h.Get(name, namespace)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if actual := resource.GetStatus().Reason; actual != reason {
+		t.Errorf("expected '%s' status reason to be '%s', got '%s' (message: %s)", name, reason, actual, resource.GetStatus().Message)
+	}
+}
+
+// AssertCondition checks that the status of the Resource with the specified name and namespace has a condition of the given
+// type for the dependent with the specified name, returning the condition if it does
+func (h *Harness) AssertCondition(t *testing.T, name, namespace, dependentName string, conditionType v1beta1.DependentConditionType) *v1beta1.DependentCondition {
+	t.Helper()
+	resource, err := h.Get(name, namespace)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	conditions := resource.GetStatus().Conditions
+	for i := range conditions {
+		if conditions[i].DependentName == dependentName {
+			if conditions[i].Type != conditionType {
+				t.Errorf("expected '%s' condition of '%s' to be '%s', got '%s' (message: %s)", dependentName, name, conditionType, conditions[i].Type, conditions[i].Message)
+			}
+			return &conditions[i]
+		}
+	}
+	t.Errorf("expected '%s' to have a condition for '%s', got: %v", name, dependentName, conditions)
+	return nil
+}
+
+// AssertEvent checks that an event with the specified type and reason was recorded
+func (h *Harness) AssertEvent(t *testing.T, eventType, reason string) {
+	t.Helper()
+	events := h.Events()
+	prefix := eventType + " " + reason
+	for _, event := range events {
+		if event == prefix || strings.HasPrefix(event, prefix+" ") {
+			return
+		}
+	}
+	t.Errorf("expected a '%s' event with '%s' reason, got: %v", eventType, reason, events)
+}
@@ -0,0 +1,252 @@
+package frameworktest
+
+import (
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"testing"
+	"time"
+)
+
+// capabilityResource is a minimal Resource creating a Secret and, on OpenShift, a Role
+type capabilityResource struct {
+	*halkyon.Capability
+	*framework.BaseResource
+}
+
+var _ framework.Resource = &capabilityResource{}
+
+func newCapabilityResource() *capabilityResource {
+	c := &capabilityResource{Capability: &halkyon.Capability{}}
+	c.BaseResource = framework.NewBaseResource(c)
+	return c
+}
+
+func (c *capabilityResource) GetStatus() v1beta1.Status {
+	return c.Capability.Status.Status
+}
+
+func (c *capabilityResource) SetStatus(status v1beta1.Status) {
+	c.Capability.Status.Status = status
+}
+
+func (c *capabilityResource) Handle(err error) (bool, v1beta1.Status) {
+	return framework.DefaultErrorHandler(c.GetStatus(), err)
+}
+
+func (c *capabilityResource) CheckValidity() error {
+	return nil
+}
+
+func (c *capabilityResource) ProvideDefaultValues() bool {
+	return false
+}
+
+func (c *capabilityResource) GetUnderlyingAPIResource() framework.SerializableResource {
+	return c.Capability
+}
+
+func (c *capabilityResource) Delete() error {
+	return nil
+}
+
+func (c *capabilityResource) CreateOrUpdate() error {
+	return c.CreateOrUpdateDependents()
+}
+
+func (c *capabilityResource) NewEmpty() framework.Resource {
+	return newCapabilityResource()
+}
+
+func (c *capabilityResource) InitDependentResources() ([]framework.DependentResource, error) {
+	dependents := c.AddDependentResource(framework.NewSecret(credentials{owner: c.Capability}))
+	if c.GetHelper().IsTargetClusterRunningOpenShift() {
+		dependents = c.AddDependentResource(framework.NewOwnedRole(role{owner: c.Capability}))
+	}
+	return dependents, nil
+}
+
+func (c *capabilityResource) ComputeStatus() bool {
+	needsUpdate := c.BaseResource.ComputeStatus()
+	if status := c.GetStatus(); status.Reason != "Ready" {
+		status.Reason = "Ready"
+		c.SetStatus(status)
+		return true
+	}
+	return needsUpdate
+}
+
+type credentials struct {
+	owner framework.SerializableResource
+}
+
+func (c credentials) GetDataMap() map[string][]byte {
+	return map[string][]byte{"user": []byte(c.owner.GetName())}
+}
+
+func (c credentials) GetSecretName() string {
+	return framework.DefaultSecretNameFrom(c.owner)
+}
+
+func (c credentials) Owner() framework.SerializableResource {
+	return c.owner
+}
+
+type role struct {
+	owner framework.SerializableResource
+}
+
+func (r role) GetRoleName() string {
+	return r.owner.GetName() + "-role"
+}
+
+func (r role) Owner() framework.SerializableResource {
+	return r.owner
+}
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := halkyon.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newCapability(name string) *halkyon.Capability {
+	return &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test"},
+		Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: "11"},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name             string
+		openShiftVersion int
+		expectRole       bool
+	}{
+		{"kubernetes", 0, false},
+		{"openshift 3", 3, true},
+		{"openshift 4", 4, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			h := NewHarness(newScheme(t), newCapabilityResource(), Config{
+				Objects:          []runtime.Object{newCapability("db")},
+				OpenShiftVersion: tt.openShiftVersion,
+			})
+			if _, err := h.ReconcileN("db", "test", 2); err != nil {
+				t.Fatal(err)
+			}
+
+			secret, ok := h.AssertDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "db-config", "test").(*corev1.Secret)
+			if !ok {
+				t.Fatal("expected a typed Secret")
+			}
+			if owners := secret.GetOwnerReferences(); len(owners) != 1 || owners[0].Name != "db" {
+				t.Errorf("expected secret to be owned by 'db', got %v", owners)
+			}
+			if tt.expectRole {
+				h.AssertDependent(t, framework.RoleGVK, "db-role", "test")
+			} else {
+				h.AssertNoDependent(t, framework.RoleGVK, "db-role", "test")
+			}
+			h.AssertStatusReason(t, "db", "test", "Ready")
+			h.AssertEvent(t, corev1.EventTypeNormal, "Ready")
+		})
+	}
+}
+
+func TestReconcileMissingResource(t *testing.T) {
+	h := NewHarness(newScheme(t), newCapabilityResource(), Config{})
+	if _, err := h.Reconcile("missing", "test"); err != nil {
+		t.Fatal(err)
+	}
+	h.AssertNoDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "missing-config", "test")
+	if events := h.Events(); len(events) != 0 {
+		t.Errorf("expected no events, got %v", events)
+	}
+}
+
+// requeuingResource is a capabilityResource always needing to be requeued
+type requeuingResource struct {
+	*capabilityResource
+}
+
+func (r requeuingResource) NeedsRequeue() bool {
+	return true
+}
+
+func (r requeuingResource) NewEmpty() framework.Resource {
+	return requeuingResource{capabilityResource: newCapabilityResource()}
+}
+
+func TestReconcileWithOptions(t *testing.T) {
+	t.Run("namespaces", func(t *testing.T) {
+		h := NewHarness(newScheme(t), newCapabilityResource(), Config{
+			Objects: []runtime.Object{newCapability("db")},
+			Options: framework.ControllerOptions{Namespaces: []string{"other"}},
+		})
+		if _, err := h.Reconcile("db", "test"); err != nil {
+			t.Fatal(err)
+		}
+		h.AssertNoDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "db-config", "test")
+	})
+
+	t.Run("resync", func(t *testing.T) {
+		h := NewHarness(newScheme(t), newCapabilityResource(), Config{
+			Objects: []runtime.Object{newCapability("db")},
+			Options: framework.ControllerOptions{Namespaces: []string{"test"}, ResyncPeriod: time.Hour, ReconcileTimeout: time.Minute},
+		})
+		result, err := h.Reconcile("db", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Requeue || result.RequeueAfter != time.Hour {
+			t.Errorf("expected resource to be resynced after an hour, got %+v", result)
+		}
+		h.AssertDependent(t, corev1.SchemeGroupVersion.WithKind("Secret"), "db-config", "test")
+	})
+
+	t.Run("missing resource not resynced", func(t *testing.T) {
+		h := NewHarness(newScheme(t), newCapabilityResource(), Config{
+			Options: framework.ControllerOptions{ResyncPeriod: time.Hour},
+		})
+		result, err := h.Reconcile("missing", "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Requeue || result.RequeueAfter != 0 {
+			t.Errorf("expected missing resource not to be resynced, got %+v", result)
+		}
+	})
+
+	t.Run("rate limiter", func(t *testing.T) {
+		h := NewHarness(newScheme(t), requeuingResource{capabilityResource: newCapabilityResource()}, Config{
+			Objects: []runtime.Object{newCapability("db")},
+			Options: framework.ControllerOptions{
+				RateLimiter:  workqueue.NewItemExponentialFailureRateLimiter(time.Second, time.Minute),
+				ResyncPeriod: time.Hour,
+			},
+		})
+		for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+			result, err := h.Reconcile("db", "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Requeue || result.RequeueAfter != expected {
+				t.Errorf("expected resource to be requeued after %s, got %+v", expected, result)
+			}
+		}
+	})
+}
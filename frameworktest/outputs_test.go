@@ -0,0 +1,202 @@
+package frameworktest
+
+import (
+	"fmt"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"reflect"
+	"testing"
+)
+
+var databaseGVK = schema.GroupVersionKind{Group: "test.halkyon.io", Version: "v1", Kind: "Database"}
+
+// database is an API type recording the outputs it publishes in its status
+type database struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+	Status        databaseStatus `json:"status,omitempty"`
+}
+
+type databaseStatus struct {
+	v1beta1.Status `json:",inline"`
+	Outputs        []v1beta1.NameValuePair `json:"outputs,omitempty"`
+}
+
+func (in *database) DeepCopyObject() runtime.Object {
+	out := &database{TypeMeta: in.TypeMeta, Status: in.Status}
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Status.Conditions = append([]v1beta1.DependentCondition(nil), in.Status.Conditions...)
+	out.Status.Outputs = append([]v1beta1.NameValuePair(nil), in.Status.Outputs...)
+	return out
+}
+
+func (in *database) GetGroupVersionKind() schema.GroupVersionKind {
+	return databaseGVK
+}
+
+// outputs records the outputs databaseResources provide, the error they return and whether creating their dependents fails
+type outputs struct {
+	values       []v1beta1.NameValuePair
+	err          error
+	createFailed bool
+	calls        int
+}
+
+// databaseResource is a minimal Resource publishing outputs
+type databaseResource struct {
+	*database
+	*framework.BaseResource
+	outputs *outputs
+}
+
+var _ framework.OutputsPublisher = &databaseResource{}
+
+func newDatabaseResource(outputs *outputs) *databaseResource {
+	d := &databaseResource{database: &database{}, outputs: outputs}
+	d.BaseResource = framework.NewBaseResource(d)
+	return d
+}
+
+func (d *databaseResource) GetOutputs() ([]v1beta1.NameValuePair, error) {
+	d.outputs.calls++
+	return d.outputs.values, d.outputs.err
+}
+
+func (d *databaseResource) SetOutputs(outputs []v1beta1.NameValuePair) bool {
+	if reflect.DeepEqual(d.database.Status.Outputs, outputs) {
+		return false
+	}
+	d.database.Status.Outputs = outputs
+	return true
+}
+
+func (d *databaseResource) GetStatus() v1beta1.Status {
+	return d.database.Status.Status
+}
+
+func (d *databaseResource) SetStatus(status v1beta1.Status) {
+	d.database.Status.Status = status
+}
+
+func (d *databaseResource) Handle(err error) (bool, v1beta1.Status) {
+	return framework.DefaultErrorHandler(d.GetStatus(), err)
+}
+
+func (d *databaseResource) CheckValidity() error {
+	return nil
+}
+
+func (d *databaseResource) ProvideDefaultValues() bool {
+	return false
+}
+
+func (d *databaseResource) GetUnderlyingAPIResource() framework.SerializableResource {
+	return d.database
+}
+
+func (d *databaseResource) Delete() error {
+	return nil
+}
+
+func (d *databaseResource) CreateOrUpdate() error {
+	if d.outputs.createFailed {
+		return fmt.Errorf("couldn't create dependents")
+	}
+	return d.CreateOrUpdateDependents()
+}
+
+func (d *databaseResource) NewEmpty() framework.Resource {
+	return newDatabaseResource(d.outputs)
+}
+
+func (d *databaseResource) InitDependentResources() ([]framework.DependentResource, error) {
+	return d.AddDependentResource(framework.NewSecret(credentials{owner: d.database})), nil
+}
+
+func (d *databaseResource) ComputeStatus() bool {
+	needsUpdate := d.BaseResource.ComputeStatus()
+	if status := d.GetStatus(); status.Reason != "Ready" {
+		status.Reason = "Ready"
+		d.SetStatus(status)
+		return true
+	}
+	return needsUpdate
+}
+
+func newDatabaseHarness(t *testing.T, outputs *outputs) *Harness {
+	s := newScheme(t)
+	s.AddKnownTypeWithName(databaseGVK, &database{})
+	db := &database{ObjectMeta: v1.ObjectMeta{Name: "db", Namespace: "test"}}
+	return NewHarness(s, newDatabaseResource(outputs), Config{Objects: []runtime.Object{db}})
+}
+
+func getDatabase(t *testing.T, h *Harness, name string) *database {
+	t.Helper()
+	resource, err := h.Get(name, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resource.GetUnderlyingAPIResource().(*database)
+}
+
+func TestOutputsAreRecordedInStatus(t *testing.T) {
+	published := []v1beta1.NameValuePair{{Name: "host", Value: "db.test.svc"}, {Name: "port", Value: "5432"}}
+	outputs := &outputs{values: published}
+	h := newDatabaseHarness(t, outputs)
+
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	db := getDatabase(t, h, "db")
+	if !reflect.DeepEqual(db.Status.Outputs, published) {
+		t.Errorf("expected outputs %v to be recorded in status, got %v", published, db.Status.Outputs)
+	}
+	if len(db.GetAnnotations()) != 0 {
+		t.Errorf("didn't expect outputs to be recorded outside of the status, got annotations %v", db.GetAnnotations())
+	}
+	h.AssertStatusReason(t, "db", "test", "Ready")
+
+	// unchanged outputs don't trigger a status update
+	version := db.GetResourceVersion()
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if actual := getDatabase(t, h, "db").GetResourceVersion(); actual != version {
+		t.Errorf("didn't expect resource to be updated when outputs didn't change, resource version went from %s to %s", version, actual)
+	}
+
+	// changed outputs are updated
+	outputs.values = []v1beta1.NameValuePair{{Name: "host", Value: "db-primary.test.svc"}}
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if actual := getDatabase(t, h, "db").Status.Outputs; !reflect.DeepEqual(actual, outputs.values) {
+		t.Errorf("expected outputs to be updated to %v, got %v", outputs.values, actual)
+	}
+}
+
+func TestOutputsAreNotRecordedWhenReconcileFails(t *testing.T) {
+	outputs := &outputs{values: []v1beta1.NameValuePair{{Name: "host", Value: "db.test.svc"}}, createFailed: true}
+	h := newDatabaseHarness(t, outputs)
+
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if outputs.calls != 0 {
+		t.Errorf("didn't expect outputs to be retrieved when dependents couldn't be created, got %d calls", outputs.calls)
+	}
+	h.AssertStatusReason(t, "db", "test", "Failed")
+
+	// failing to retrieve outputs is handled as an error
+	outputs.createFailed, outputs.err = false, fmt.Errorf("plugin unavailable")
+	if _, err := h.Reconcile("db", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if actual := getDatabase(t, h, "db").Status.Outputs; len(actual) != 0 {
+		t.Errorf("didn't expect outputs to be recorded, got %v", actual)
+	}
+	h.AssertStatusReason(t, "db", "test", "Failed")
+}
@@ -0,0 +1,485 @@
+package frameworktest
+
+import (
+	"context"
+	"fmt"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Scenario describes, typically in a YAML file, a sequence of reconcile steps for a primary Resource along with the expected
+// cluster state after each step, e.g.:
+//
+//	name: creates credentials
+//	openShiftVersion: 4
+//	resource:
+//	  apiVersion: halkyon.io/v1beta1
+//	  kind: Capability
+//	  metadata:
+//	    name: db
+//	    namespace: test
+//	  spec:
+//	    category: database
+//	    type: postgres
+//	steps:
+//	  - name: initial reconcile
+//	    reconciles: 2
+//	    expect:
+//	      status:
+//	        reason: Ready
+//	      dependents:
+//	        - apiVersion: v1
+//	          kind: Secret
+//	          metadata:
+//	            name: db-config
+//	  - name: credentials are deleted
+//	    changes:
+//	      - delete:
+//	          apiVersion: v1
+//	          kind: Secret
+//	          name: db-config
+//	    expect:
+//	      dependents:
+//	        - apiVersion: v1
+//	          kind: Secret
+//	          metadata:
+//	            name: db-config
+type Scenario struct {
+	// Name describes the scenario, defaults to the name of the file it was loaded from
+	Name string `json:"name,omitempty"`
+	// OpenShiftVersion is the major version of OpenShift the fake cluster simulates, defaults to 0, i.e. a vanilla Kubernetes
+	// cluster
+	OpenShiftVersion int `json:"openShiftVersion,omitempty"`
+	// Objects lists the objects, other than the primary Resource, the fake cluster is initialized with
+	Objects []unstructured.Unstructured `json:"objects,omitempty"`
+	// Resource is the primary Resource which is reconciled
+	Resource unstructured.Unstructured `json:"resource"`
+	// Steps lists the steps of the scenario, in order
+	Steps []Step `json:"steps"`
+}
+
+// Step describes changes to apply to the cluster, simulating external changes, before reconciling the primary Resource and
+// checking the resulting cluster state
+type Step struct {
+	// Name describes the step, defaults to its index
+	Name string `json:"name,omitempty"`
+	// Changes lists the changes to apply to the cluster, in order, before reconciling
+	Changes []Change `json:"changes,omitempty"`
+	// Reconciles is the number of times the primary Resource is reconciled, defaults to 1
+	Reconciles int `json:"reconciles,omitempty"`
+	// Expect records the expected outcome of the step
+	Expect Expectations `json:"expect,omitempty"`
+}
+
+// Change describes an external change to the cluster, only one of its fields being expected to be set
+type Change struct {
+	// Apply merges the specified object with the existing one, creating it if it doesn't exist
+	Apply *unstructured.Unstructured `json:"apply,omitempty"`
+	// Delete deletes the referenced object
+	Delete *corev1.ObjectReference `json:"delete,omitempty"`
+}
+
+// Expectations records the expected outcome of a Step. Only the specified expectations are checked.
+type Expectations struct {
+	// Error is a substring of the error expected to be returned by the last reconcile, no error being expected if empty
+	Error string `json:"error,omitempty"`
+	// Requeue records whether the last reconcile is expected to request the primary Resource to be requeued
+	Requeue *bool `json:"requeue,omitempty"`
+	// Status records the expected reason and message of the primary Resource's status
+	Status *ExpectedStatus `json:"status,omitempty"`
+	// Conditions lists the conditions expected in the primary Resource's status
+	Conditions []ExpectedCondition `json:"conditions,omitempty"`
+	// Dependents lists the objects expected to exist on the cluster. Only the specified fields are compared, the namespace
+	// defaulting to the one of the primary Resource.
+	Dependents []unstructured.Unstructured `json:"dependents,omitempty"`
+	// Absent references the objects expected not to exist on the cluster, the namespace defaulting to the one of the primary
+	// Resource
+	Absent []corev1.ObjectReference `json:"absent,omitempty"`
+	// Events lists the events expected to be recorded during the step
+	Events []ExpectedEvent `json:"events,omitempty"`
+}
+
+// ExpectedStatus records the expected reason and, if not empty, message of a Resource's status
+type ExpectedStatus struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
+}
+
+// ExpectedCondition records the expected type and, if not empty, reason of the condition associated with a dependent
+type ExpectedCondition struct {
+	DependentName string                         `json:"dependentName"`
+	Type          v1beta1.DependentConditionType `json:"type"`
+	Reason        string                         `json:"reason,omitempty"`
+}
+
+// ExpectedEvent records the type and reason of an expected event
+type ExpectedEvent struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// LoadScenario loads a Scenario from the specified YAML (or JSON) file
+func LoadScenario(path string) (Scenario, error) {
+	scenario := Scenario{}
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return scenario, err
+	}
+	if err := yaml.Unmarshal(bytes, &scenario); err != nil {
+		return scenario, fmt.Errorf("invalid scenario in '%s': %v", path, err)
+	}
+	if len(scenario.Name) == 0 {
+		scenario.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if len(scenario.Resource.GetName()) == 0 {
+		return scenario, fmt.Errorf("invalid scenario in '%s': the resource needs a name", path)
+	}
+	return scenario, nil
+}
+
+// RunScenarios runs, as sub-tests, the scenarios loaded from the files matching the specified glob pattern against Resources
+// represented by the given prototype, failing if no file matches
+func RunScenarios(t *testing.T, scheme *runtime.Scheme, prototype framework.Resource, pattern string) {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no scenario matching '%s'", pattern)
+	}
+	for _, path := range paths {
+		scenario, err := LoadScenario(path)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		t.Run(scenario.Name, func(t *testing.T) {
+			if err := scenario.Run(scheme, prototype); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Run runs this Scenario against Resources represented by the specified prototype, returning an error describing the first
+// step whose outcome didn't match the expectations
+func (s Scenario) Run(scheme *runtime.Scheme, prototype framework.Resource) error {
+	objects := make([]runtime.Object, 0, len(s.Objects)+1)
+	for _, object := range append(s.Objects, s.Resource) {
+		typed, err := toTyped(scheme, object.DeepCopy())
+		if err != nil {
+			return err
+		}
+		objects = append(objects, typed)
+	}
+	h := NewHarness(scheme, prototype, Config{Objects: objects, OpenShiftVersion: s.OpenShiftVersion})
+
+	name, namespace := s.Resource.GetName(), s.Resource.GetNamespace()
+	for i, step := range s.Steps {
+		stepName := step.Name
+		if len(stepName) == 0 {
+			stepName = fmt.Sprintf("#%d", i+1)
+		}
+		if err := step.run(h, name, namespace); err != nil {
+			return fmt.Errorf("step '%s' of '%s' scenario: %v", stepName, s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s Step) run(h *Harness, name, namespace string) error {
+	for _, change := range s.Changes {
+		if err := change.apply(h, namespace); err != nil {
+			return err
+		}
+	}
+
+	eventsBefore := len(h.Events())
+	reconciles := s.Reconciles
+	if reconciles <= 0 {
+		reconciles = 1
+	}
+	result, err := h.ReconcileN(name, namespace, reconciles)
+	mismatches := make([]string, 0, 7)
+	switch {
+	case err != nil && len(s.Expect.Error) == 0:
+		return fmt.Errorf("unexpected error: %v", err)
+	case err == nil && len(s.Expect.Error) > 0:
+		mismatches = append(mismatches, fmt.Sprintf("expected error containing '%s', got none", s.Expect.Error))
+	case err != nil && !strings.Contains(err.Error(), s.Expect.Error):
+		mismatches = append(mismatches, fmt.Sprintf("expected error containing '%s', got: %v", s.Expect.Error, err))
+	}
+	if s.Expect.Requeue != nil && *s.Expect.Requeue != result.Requeue {
+		mismatches = append(mismatches, fmt.Sprintf("expected requeue to be %v, got %v", *s.Expect.Requeue, result.Requeue))
+	}
+	mismatches = append(mismatches, s.Expect.checkResource(h, name, namespace)...)
+	mismatches = append(mismatches, s.Expect.checkDependents(h, namespace)...)
+	mismatches = append(mismatches, s.Expect.checkEvents(h.Events()[eventsBefore:])...)
+	if len(mismatches) > 0 {
+		return fmt.Errorf("unexpected outcome:\n  %s", strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+func (c Change) apply(h *Harness, defaultNamespace string) error {
+	switch {
+	case c.Apply != nil:
+		object := c.Apply.DeepCopy()
+		if len(object.GetNamespace()) == 0 {
+			object.SetNamespace(defaultNamespace)
+		}
+		existing, err := h.GetDependent(object.GroupVersionKind(), object.GetName(), object.GetNamespace())
+		if errors.IsNotFound(err) {
+			typed, err := toTyped(h.Scheme, object)
+			if err != nil {
+				return err
+			}
+			return h.Client.Create(context.TODO(), typed)
+		}
+		if err != nil {
+			return err
+		}
+		current, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+		if err != nil {
+			return err
+		}
+		merged := &unstructured.Unstructured{Object: merge(current, object.Object)}
+		typed, err := toTyped(h.Scheme, merged)
+		if err != nil {
+			return err
+		}
+		return h.Client.Update(context.TODO(), typed)
+	case c.Delete != nil:
+		namespace := c.Delete.Namespace
+		if len(namespace) == 0 {
+			namespace = defaultNamespace
+		}
+		gvk := schema.FromAPIVersionAndKind(c.Delete.APIVersion, c.Delete.Kind)
+		existing, err := h.GetDependent(gvk, c.Delete.Name, namespace)
+		if err != nil {
+			return err
+		}
+		return h.Client.Delete(context.TODO(), existing)
+	default:
+		return fmt.Errorf("change needs to either apply or delete an object")
+	}
+}
+
+func (e Expectations) checkResource(h *Harness, name, namespace string) []string {
+	if e.Status == nil && len(e.Conditions) == 0 {
+		return nil
+	}
+	resource, err := h.Get(name, namespace)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	status := resource.GetStatus()
+	mismatches := make([]string, 0, len(e.Conditions)+2)
+	if e.Status != nil {
+		if status.Reason != e.Status.Reason {
+			mismatches = append(mismatches, fmt.Sprintf("status.reason: expected '%s', got '%s' (message: %s)", e.Status.Reason, status.Reason, status.Message))
+		}
+		if len(e.Status.Message) > 0 && status.Message != e.Status.Message {
+			mismatches = append(mismatches, fmt.Sprintf("status.message: expected '%s', got '%s'", e.Status.Message, status.Message))
+		}
+	}
+	for _, expected := range e.Conditions {
+		condition := conditionFor(status, expected.DependentName)
+		switch {
+		case condition == nil:
+			mismatches = append(mismatches, fmt.Sprintf("condition '%s': missing", expected.DependentName))
+		case condition.Type != expected.Type:
+			mismatches = append(mismatches, fmt.Sprintf("condition '%s': expected type '%s', got '%s' (message: %s)", expected.DependentName, expected.Type, condition.Type, condition.Message))
+		case len(expected.Reason) > 0 && condition.Reason != expected.Reason:
+			mismatches = append(mismatches, fmt.Sprintf("condition '%s': expected reason '%s', got '%s'", expected.DependentName, expected.Reason, condition.Reason))
+		}
+	}
+	return mismatches
+}
+
+func (e Expectations) checkDependents(h *Harness, defaultNamespace string) []string {
+	mismatches := make([]string, 0, len(e.Dependents)+len(e.Absent))
+	for _, expected := range e.Dependents {
+		namespace := expected.GetNamespace()
+		if len(namespace) == 0 {
+			namespace = defaultNamespace
+		}
+		gvk := expected.GroupVersionKind()
+		description := fmt.Sprintf("%s '%s'", gvk.Kind, expected.GetName())
+		actual, err := h.GetDependent(gvk, expected.GetName(), namespace)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", description, err))
+			continue
+		}
+		actualMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(actual)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", description, err))
+			continue
+		}
+		expectedMap := expected.DeepCopy().Object
+		// identity fields are already used to fetch the object
+		delete(expectedMap, "apiVersion")
+		delete(expectedMap, "kind")
+		if metadata, ok := expectedMap["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "namespace")
+		}
+		for _, diff := range diff("", expectedMap, actualMap) {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s", description, diff))
+		}
+	}
+	for _, absent := range e.Absent {
+		namespace := absent.Namespace
+		if len(namespace) == 0 {
+			namespace = defaultNamespace
+		}
+		gvk := schema.FromAPIVersionAndKind(absent.APIVersion, absent.Kind)
+		if _, err := h.GetDependent(gvk, absent.Name, namespace); !errors.IsNotFound(err) {
+			mismatches = append(mismatches, fmt.Sprintf("%s '%s': expected to be absent, got: %v", gvk.Kind, absent.Name, err))
+		}
+	}
+	return mismatches
+}
+
+func (e Expectations) checkEvents(events []string) []string {
+	mismatches := make([]string, 0, len(e.Events))
+	for _, expected := range e.Events {
+		prefix := expected.Type + " " + expected.Reason
+		found := false
+		for _, event := range events {
+			if event == prefix || strings.HasPrefix(event, prefix+" ") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf("expected a '%s' event with '%s' reason, got: %v", expected.Type, expected.Reason, events))
+		}
+	}
+	return mismatches
+}
+
+func conditionFor(status v1beta1.Status, dependentName string) *v1beta1.DependentCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].DependentName == dependentName {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// toTyped converts the specified Unstructured to the associated typed object if its type is known to the scheme
+func toTyped(scheme *runtime.Scheme, u *unstructured.Unstructured) (runtime.Object, error) {
+	typed, err := scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return u, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return nil, fmt.Errorf("invalid %s '%s': %v", u.GetKind(), u.GetName(), err)
+	}
+	return typed, nil
+}
+
+// merge recursively merges the values of the specified patch into the given object, returning the merged object
+func merge(object, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		patchMap, isMap := value.(map[string]interface{})
+		objectMap, existingIsMap := object[key].(map[string]interface{})
+		if isMap && existingIsMap {
+			object[key] = merge(objectMap, patchMap)
+		} else {
+			object[key] = value
+		}
+	}
+	return object
+}
+
+// diff lists the differences between the specified expected and actual values, only the fields present in the expected value
+// being compared. Lists need to match element-wise.
+func diff(path string, expected, actual interface{}) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object, got %s", pathOrRoot(path), describe(actual))}
+		}
+		keys := make([]string, 0, len(e))
+		for key := range e {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		diffs := make([]string, 0, len(keys))
+		for _, key := range keys {
+			childPath := key
+			if len(path) > 0 {
+				childPath = path + "." + key
+			}
+			value, present := a[key]
+			if !present {
+				diffs = append(diffs, fmt.Sprintf("%s: expected %s, got nothing", childPath, describe(e[key])))
+				continue
+			}
+			diffs = append(diffs, diff(childPath, e[key], value)...)
+		}
+		return diffs
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", pathOrRoot(path), describe(expected), describe(actual))}
+		}
+		diffs := make([]string, 0, len(e))
+		for i := range e {
+			diffs = append(diffs, diff(fmt.Sprintf("%s[%d]", path, i), e[i], a[i])...)
+		}
+		return diffs
+	default:
+		if !reflect.DeepEqual(normalize(expected), normalize(actual)) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", pathOrRoot(path), describe(expected), describe(actual))}
+		}
+		return nil
+	}
+}
+
+// normalize converts numbers to float64 since YAML and the unstructured converter don't use the same numeric types
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return value
+	}
+}
+
+func describe(value interface{}) string {
+	if value == nil {
+		return "nothing"
+	}
+	bytes, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return strings.TrimSpace(string(bytes))
+}
+
+func pathOrRoot(path string) string {
+	if len(path) == 0 {
+		return "<root>"
+	}
+	return path
+}
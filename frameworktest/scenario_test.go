@@ -0,0 +1,82 @@
+package frameworktest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScenarios(t *testing.T) {
+	RunScenarios(t, newScheme(t), newCapabilityResource(), "testdata/scenarios/*.yaml")
+}
+
+func TestScenarioMismatch(t *testing.T) {
+	scenario, err := LoadScenario("testdata/scenarios/credentials.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario.Steps[0].Expect.Dependents[0].Object["data"] = map[string]interface{}{"user": "Zm9v"}
+	err = scenario.Run(newScheme(t), newCapabilityResource())
+	if err == nil {
+		t.Fatal("expected scenario to fail")
+	}
+	expected := `Secret 'db-config': data.user: expected Zm9v, got ZGI=`
+	if !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error to contain '%s', got: %v", expected, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		diffs    []string
+	}{
+		{
+			name:     "subset",
+			expected: map[string]interface{}{"a": "b"},
+			actual:   map[string]interface{}{"a": "b", "c": "d"},
+		},
+		{
+			name:     "numbers",
+			expected: map[string]interface{}{"replicas": float64(2)},
+			actual:   map[string]interface{}{"replicas": int64(2)},
+		},
+		{
+			name:     "different value",
+			expected: map[string]interface{}{"spec": map[string]interface{}{"replicas": float64(2)}},
+			actual:   map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(1)}},
+			diffs:    []string{"spec.replicas: expected 2, got 1"},
+		},
+		{
+			name:     "missing field",
+			expected: map[string]interface{}{"a": "b"},
+			actual:   map[string]interface{}{},
+			diffs:    []string{"a: expected b, got nothing"},
+		},
+		{
+			name:     "list element",
+			expected: map[string]interface{}{"items": []interface{}{"a", "b"}},
+			actual:   map[string]interface{}{"items": []interface{}{"a", "c"}},
+			diffs:    []string{"items[1]: expected b, got c"},
+		},
+		{
+			name:     "list length",
+			expected: map[string]interface{}{"items": []interface{}{"a"}},
+			actual:   map[string]interface{}{"items": []interface{}{"a", "c"}},
+			diffs:    []string{"items: expected - a, got - a\n- c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs := diff("", tt.expected, tt.actual)
+			if len(diffs) == 0 && len(tt.diffs) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(diffs, tt.diffs) {
+				t.Errorf("diff() = %q, expected %q", diffs, tt.diffs)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/go-logr/logr"
 	"halkyon.io/api/v1beta1"
 	"halkyon.io/operator-framework/util"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -16,21 +17,41 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strings"
-	"sync"
 )
 
 // GenericReconciler implements Reconciler in a generic way as it pertains to reconciling a Resource
 type GenericReconciler struct {
 	resource Resource
+	helper   *K8SHelper
+	options  ControllerOptions
 }
 
 // blank assignment to make sure we implement Reconciler
 var _ reconcile.Reconciler = &GenericReconciler{}
+var _ HelperAware = &GenericReconciler{}
 
 // NewGenericReconciler creates a new GenericReconciler that can handle resources represented by the specified Resource, which
-// acts as a prototype standing in for instances that will be reconciled.
+// acts as a prototype standing in for instances that will be reconciled. The reconciler uses the same K8SHelper as the
+// prototype, see HelperFor.
 func NewGenericReconciler(resource Resource) *GenericReconciler {
-	return &GenericReconciler{resource: resource}
+	return NewConfiguredGenericReconciler(resource, ControllerOptions{})
+}
+
+// NewConfiguredGenericReconciler creates a new GenericReconciler, as NewGenericReconciler does, processing Resources according
+// to the specified ControllerOptions. Note that MaxConcurrentReconciles is only taken into account by the controller.
+func NewConfiguredGenericReconciler(resource Resource, options ControllerOptions) *GenericReconciler {
+	return &GenericReconciler{resource: resource, helper: HelperFor(resource), options: options}
+}
+
+// GetHelper returns the K8SHelper this GenericReconciler uses to interact with the cluster
+func (b *GenericReconciler) GetHelper() *K8SHelper {
+	return b.helper
+}
+
+// SetHelper sets the K8SHelper this GenericReconciler uses to interact with the cluster, which is passed on to the HelperAware
+// Resources it reconciles
+func (b *GenericReconciler) SetHelper(helper *K8SHelper) {
+	b.helper = helper
 }
 
 func (b *GenericReconciler) logger() logr.Logger {
@@ -38,6 +59,29 @@ func (b *GenericReconciler) logger() logr.Logger {
 }
 
 func (b *GenericReconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	if !b.options.handles(request.Namespace) {
+		return reconcile.Result{}, nil
+	}
+
+	ctx := context.Background()
+	if b.options.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.options.ReconcileTimeout)
+		defer cancel()
+	}
+
+	result, found, err := b.reconcile(ctx, request)
+	result, handled := b.options.schedule(request, result, err, found)
+	if handled {
+		b.logger().Error(err, fmt.Sprintf("failed to reconcile '%s' %s, retrying in %s", request.Name, util.GetObjectName(b.resource), result.RequeueAfter))
+		return result, nil
+	}
+	return result, err
+}
+
+// reconcile reconciles the Resource identified by the specified request using the given context, also returning whether the
+// Resource was found on the cluster
+func (b *GenericReconciler) reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, bool, error) {
 	b.logger().WithValues("namespace", request.Namespace)
 	typeName := util.GetObjectName(b.resource)
 
@@ -46,41 +90,55 @@ func (b *GenericReconciler) Reconcile(request reconcile.Request) (reconcile.Resu
 	// Initialize it from the cluster state, using the name / namespace from the reconcile request
 	resource.SetName(request.Name)
 	resource.SetNamespace(request.Namespace)
-	_, err := Helper.Fetch(request.Name, request.Namespace, resource.GetUnderlyingAPIResource())
+	helper := b.GetHelper()
+	if aware, ok := resource.(HelperAware); ok {
+		aware.SetHelper(helper)
+	}
+	_, err := helper.FetchWithContext(ctx, request.Name, request.Namespace, resource.GetUnderlyingAPIResource())
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Return and don't create
 			b.logger().Info("'" + request.Name + "' " + typeName + " is marked for deletion. Running clean-up.")
+			if registry := WatchRegistryFor(b.resource); registry != nil {
+				registry.forget(request.NamespacedName)
+			}
 			err := resource.Delete()
-			return reconcile.Result{}, err
+			return reconcile.Result{}, false, err
 		}
 		// Error reading the object - create the request.
 		b.logger().Error(err, "failed to initialize '"+request.Name+"' "+typeName)
 		if resource != nil {
-			err = UpdateStatusIfNeeded(resource, err)
-			return reconcile.Result{}, nil
+			err = UpdateStatusIfNeededWithContext(ctx, resource, err)
+			return reconcile.Result{}, true, nil
+		}
+		return reconcile.Result{}, true, err
+	}
+
+	// Handle finalization if needed
+	if finalizable, ok := resource.(Finalizable); ok {
+		if done, result, err := b.handleFinalization(ctx, resource, finalizable); done {
+			return result, true, err
 		}
-		return reconcile.Result{}, err
 	}
 
 	// Initialize with default values if needed
 	if resource.ProvideDefaultValues() {
-		if e := Helper.Client.Update(context.Background(), resource.GetUnderlyingAPIResource()); e != nil {
+		if e := helper.Client.Update(ctx, resource.GetUnderlyingAPIResource()); e != nil {
 			b.logger().Error(e, fmt.Sprintf("failed to update '%s' %s", resource.GetName(), typeName))
 		}
-		return reconcile.Result{}, nil
+		return reconcile.Result{}, true, nil
 	}
 
 	// Check the validity of the resource
 	if err := resource.CheckValidity(); err != nil {
-		err = UpdateStatusIfNeeded(resource, fmt.Errorf("validation error(s): %v", err))
-		return reconcile.Result{}, err
+		err = UpdateStatusIfNeededWithContext(ctx, resource, fmt.Errorf("validation error(s): %v", err))
+		return reconcile.Result{}, true, err
 	}
 
 	// Initialize dependents
 	dependents, err := resource.InitDependentResources()
 	if err != nil {
-		return reconcile.Result{}, err
+		return reconcile.Result{}, true, err
 	}
 
 	// Initialize status if needed
@@ -88,58 +146,72 @@ func (b *GenericReconciler) Reconcile(request reconcile.Request) (reconcile.Resu
 	if len(status.Conditions) == 0 {
 		status.Conditions = make([]v1beta1.DependentCondition, 0, len(dependents))
 	}
-	for _, dependent := range dependents {
-		// add watch if needed
-		config := dependent.GetConfig()
-		if config.Watched {
-			if err := getCallbackFor(b.resource)(dependent.Owner(), config.GroupVersionKind); err != nil {
-				return reconcile.Result{}, err
-			}
+	// watch dependents whose type wasn't declared when the controller was registered, if any, and record the objects this
+	// resource references
+	// registry is nil if the reconciler wasn't registered with a manager, e.g. when testing, in which case there's nothing to watch
+	if registry := WatchRegistryFor(b.resource); registry != nil {
+		if err := registry.watchDependents(request.NamespacedName, dependents); err != nil {
+			return reconcile.Result{}, true, err
 		}
 	}
 	resource.SetStatus(status)
 	initialStatus := status.Reason
 	b.logger().Info("-> "+typeName, "name", resource.GetName(), "status", initialStatus)
 
-	err = resource.CreateOrUpdate()
+	err = createOrUpdate(ctx, resource)
 
 	// always check status for updates
-	if err = UpdateStatusIfNeeded(resource, err); err != nil {
-		return reconcile.Result{}, err
+	if err = UpdateStatusIfNeededWithContext(ctx, resource, err); err != nil {
+		return reconcile.Result{}, true, err
 	}
 
 	requeue := resource.NeedsRequeue()
 
-	// only log exit if status changed to avoid being too verbose
-	if status.Reason != initialStatus {
+	// only log exit and record an event if status changed to avoid being too verbose
+	if status = resource.GetStatus(); status.Reason != initialStatus {
 		msg := "<- " + typeName
 		if requeue {
 			msg += " (requeued)"
 		}
 		b.logger().Info(msg, "name", resource.GetName(), "status", status.Reason)
+		eventType := corev1.EventTypeNormal
+		if status.Reason == failedReason {
+			eventType = corev1.EventTypeWarning
+		}
+		helper.RecordEvent(resource.GetUnderlyingAPIResource(), eventType, status.Reason, status.Message)
 	}
-	return reconcile.Result{Requeue: requeue}, nil
+	return reconcile.Result{Requeue: requeue}, true, nil
 }
 
-// UpdateStatusIfNeeded updates the status of the specified Resource, computing its status or handling the specified error
-// if it's not nil
+// UpdateStatusIfNeeded updates the status of the specified Resource, computing its status, including its outputs if it's an
+// OutputsPublisher, or handling the specified error if it's not nil. Failing to retrieve outputs is handled as an error.
 func UpdateStatusIfNeeded(instance Resource, err error) error {
+	return UpdateStatusIfNeededWithContext(context.Background(), instance, err)
+}
+
+// UpdateStatusIfNeededWithContext is the context-aware version of UpdateStatusIfNeeded
+func UpdateStatusIfNeededWithContext(ctx context.Context, instance Resource, err error) error {
 	// update the resource if the status has changed
 	object := instance.GetUnderlyingAPIResource()
 	logger := LoggerFor(object)
 	updateStatus := false
 	if err == nil {
-		updateStatus = instance.ComputeStatus()
-	} else {
-		status := instance.GetStatus()
-		updateStatus, status = instance.Handle(err)
-		if updateStatus {
+		updateStatus = computeStatus(ctx, instance)
+		if publisher, ok := instance.(OutputsPublisher); ok {
+			var changed bool
+			changed, err = publishOutputs(publisher)
+			updateStatus = updateStatus || changed
+		}
+	}
+	if err != nil {
+		if handled, status := instance.Handle(err); handled {
 			instance.SetStatus(status)
+			updateStatus = true
 		}
 		logger.Error(err, fmt.Sprintf("'%s' %s has an error", instance.GetName(), util.GetObjectName(instance.GetUnderlyingAPIResource())))
 	}
 	if updateStatus {
-		if e := Helper.Client.Status().Update(context.Background(), object); e != nil {
+		if e := HelperFor(instance).Client.Status().Update(ctx, object); e != nil {
 			logger.Error(e, fmt.Sprintf("failed to update status for '%s' %s", instance.GetName(), util.GetObjectName(object)))
 			return e
 		}
@@ -147,15 +219,41 @@ func UpdateStatusIfNeeded(instance Resource, err error) error {
 	return nil
 }
 
+// computeStatus computes the status of the specified Resource, passing it the given context if it's a ContextAwareResource
+func computeStatus(ctx context.Context, resource Resource) bool {
+	if aware, ok := resource.(ContextAwareResource); ok {
+		return aware.ComputeStatusWithContext(ctx)
+	}
+	return resource.ComputeStatus()
+}
+
+// createOrUpdate creates or updates the dependents of the specified Resource, passing it the given context if it's a
+// ContextAwareResource
+func createOrUpdate(ctx context.Context, resource Resource) error {
+	if aware, ok := resource.(ContextAwareResource); ok {
+		return aware.CreateOrUpdateWithContext(ctx)
+	}
+	return resource.CreateOrUpdate()
+}
+
 // RegisterNewReconciler creates a new GenericReconciler for the specified Resource and register it with the specified Manager,
 // setting up watches as needed depending on the Resource and its DependentResources configuration
 func RegisterNewReconciler(resource Resource, mgr manager.Manager) error {
+	return RegisterNewConfiguredReconciler(resource, mgr, ControllerOptions{})
+}
+
+// RegisterNewConfiguredReconciler registers a new GenericReconciler for the specified Resource, as RegisterNewReconciler does,
+// configuring the associated controller using the specified ControllerOptions
+func RegisterNewConfiguredReconciler(resource Resource, mgr manager.Manager, options ControllerOptions) error {
 	resourceType := resource.GetUnderlyingAPIResource()
 
 	// Create a new controller
 	controllerName := controllerNameFor(resourceType)
-	reconciler := NewGenericReconciler(resource)
-	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: reconciler})
+	reconciler := NewConfiguredGenericReconciler(resource, options)
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		Reconciler:              reconciler,
+		MaxConcurrentReconciles: options.MaxConcurrentReconciles,
+	})
 	if err != nil {
 		return err
 	}
@@ -163,51 +261,21 @@ func RegisterNewReconciler(resource Resource, mgr manager.Manager) error {
 	// Register logger
 	registerLogger(controllerName)
 
-	// Watch for changes to primary resource
-	if err = c.Watch(&source.Kind{Type: resourceType}, &handler.EnqueueRequestForObject{}); err != nil {
-		return err
-	}
-
-	// Create callback for dependent resources to add themselves as watched resources
-	callbacks[controllerName] = createCallbackFor(c)
-
-	return nil
-}
-
-type WatchCallback func(owner SerializableResource, dependentGVK schema.GroupVersionKind) error
-
-var (
-	callbacks = make(map[string]WatchCallback, 7)
-	// record which gvks we're already watching to not register another watch again
-	watched = make(map[schema.GroupVersionKind]bool, 21)
-	mutex   = &sync.Mutex{}
-)
-
-func createCallbackFor(c controller.Controller) WatchCallback {
-	return func(resource SerializableResource, dependentGVK schema.GroupVersionKind) error {
-		// if we're not already watching this secondary resource
-		mutex.Lock()
-		notAlreadyWatched := !watched[dependentGVK]
-		mutex.Unlock()
-		if notAlreadyWatched {
-			// watch it
-			owner := &handler.EnqueueRequestForOwner{
-				IsController: true,
-				OwnerType:    CreateEmptyUnstructured(resource.GetGroupVersionKind()),
-			}
-			if err := c.Watch(createSourceForGVK(dependentGVK), owner); err != nil {
-				return err
-			}
-			mutex.Lock()
-			watched[dependentGVK] = true
-			mutex.Unlock()
+	// Watch for changes to primary resource, filtering events if needed
+	predicates := options.predicates()
+	if filtered, ok := resource.(EventFiltered); ok {
+		filters, err := filtered.GetEventFilter().Predicates()
+		if err != nil {
+			return err
 		}
-		return nil
+		predicates = append(predicates, filters...)
+	}
+	if err = c.Watch(&source.Kind{Type: resourceType}, &handler.EnqueueRequestForObject{}, predicates...); err != nil {
+		return err
 	}
-}
 
-func getCallbackFor(resource Resource) WatchCallback {
-	return callbacks[controllerNameFor(resource.GetUnderlyingAPIResource())]
+	// Create registry for dependent resources to add themselves as watched resources and watch the declared dependent types
+	return newWatchRegistry(controllerName, c).watchDeclaredTypes(resource)
 }
 
 func controllerNameFor(resource SerializableResource) string {
@@ -13,6 +13,7 @@ require (
 	k8s.io/code-generator v0.17.0 // indirect
 	k8s.io/gengo v0.0.0-20191120174120-e74f70b9b27e // indirect
 	sigs.k8s.io/controller-runtime v0.3.0
+	sigs.k8s.io/yaml v1.1.0
 )
 
 replace (
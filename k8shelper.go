@@ -9,27 +9,86 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sync"
 )
 
-var loggers = make(map[string]logr.Logger, 7)
+var (
+	loggers      = make(map[string]logr.Logger, 7)
+	loggersMutex = &sync.RWMutex{}
+)
 
 // K8SHelper provides access to, and ways to interact with, the Kubernetes environment we're running on
 type K8SHelper struct {
 	Client client.Client
 	Config *rest.Config
 	Scheme *runtime.Scheme
+	// Recorder records Kubernetes events, e.g. when the status of a reconciled Resource changes. Events are not recorded unless
+	// a Recorder is explicitly set, NewK8SHelper not setting any.
+	Recorder  record.EventRecorder
+	openShift *openShiftInfo
 }
 
-// Helper provides easy access to the K8SHelper that has been set up when the operator called InitHelper
+// Helper provides easy access to the K8SHelper that has been set up when the operator called InitHelper. It is the default
+// K8SHelper used by objects which weren't explicitly given one, see HelperAware.
 var Helper K8SHelper
 
+// HelperAware is implemented by objects carrying the K8SHelper they use to interact with the cluster, which makes it possible to
+// run several managers, e.g. targeting different clusters, in the same process or to test in parallel. GenericReconciler,
+// BaseResource and BaseDependentResource implement it.
+type HelperAware interface {
+	// GetHelper returns the K8SHelper this object uses
+	GetHelper() *K8SHelper
+	// SetHelper sets the K8SHelper this object uses
+	SetHelper(helper *K8SHelper)
+}
+
+// HelperFor returns the K8SHelper the specified object uses if it's HelperAware, the default Helper otherwise
+func HelperFor(obj interface{}) *K8SHelper {
+	if aware, ok := obj.(HelperAware); ok {
+		if helper := aware.GetHelper(); helper != nil {
+			return helper
+		}
+	}
+	return &Helper
+}
+
+// NewK8SHelper creates a new K8SHelper with the context provided by the specified Manager instance, determining whether the
+// associated cluster is running OpenShift
+func NewK8SHelper(mgr manager.Manager) (*K8SHelper, error) {
+	config := mgr.GetConfig()
+	version, err := detectOpenShiftVersion(config)
+	if err != nil {
+		return nil, err
+	}
+	return &K8SHelper{
+		Client:    mgr.GetClient(),
+		Config:    config,
+		Scheme:    mgr.GetScheme(),
+		openShift: &openShiftInfo{version: version},
+	}, nil
+}
+
+// RecordEvent records a Kubernetes event with the specified type, reason and message for the given object if a Recorder was set
+// on this K8SHelper, doing nothing otherwise
+func (rh K8SHelper) RecordEvent(object runtime.Object, eventType, reason, message string) {
+	if rh.Recorder != nil {
+		rh.Recorder.Event(object, eventType, reason, message)
+	}
+}
+
 // Fetch fetches the resource identified by the specified name and namespace into the given Object, returning the fetched Object
 // or an error with a useful error message (only NotFound errors are passed through as-is) if something went wrong
 func (rh K8SHelper) Fetch(name, namespace string, into runtime.Object) (runtime.Object, error) {
-	if err := rh.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, into); err != nil {
+	return rh.FetchWithContext(context.TODO(), name, namespace, into)
+}
+
+// FetchWithContext is the context-aware version of Fetch
+func (rh K8SHelper) FetchWithContext(ctx context.Context, name, namespace string, into runtime.Object) (runtime.Object, error) {
+	if err := rh.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, into); err != nil {
 		if errors.IsNotFound(err) {
 			return into, err
 		}
@@ -41,25 +100,31 @@ func (rh K8SHelper) Fetch(name, namespace string, into runtime.Object) (runtime.
 // LoggerFor retrieves a logger appropriate for the specified SerializableResource
 func LoggerFor(resourceType SerializableResource) logr.Logger {
 	name := controllerNameFor(resourceType)
-	return loggers[name]
+	loggersMutex.RLock()
+	logger, ok := loggers[name]
+	loggersMutex.RUnlock()
+	if ok {
+		return logger
+	}
+	// no controller was registered for this type (e.g. when testing), so fall back to a new logger
+	return log.Log.WithName(name)
 }
 
-// Initializes the helper with the context provided by the specified Manager instance. This needs to be called early on by the
-// operator when it is setup and before any Resource-related operations occur.
+// Initializes the default Helper with the context provided by the specified Manager instance. This needs to be called early on
+// by the operator when it is setup and before any Resource-related operations occur, unless all the objects interacting with
+// the cluster are explicitly given a K8SHelper created using NewK8SHelper.
 func InitHelper(mgr manager.Manager) {
-	config := mgr.GetConfig()
-	Helper = K8SHelper{
-		Client: mgr.GetClient(),
-		Config: config,
-		Scheme: mgr.GetScheme(),
+	helper, err := NewK8SHelper(mgr)
+	if err != nil {
+		panic(err)
 	}
-	checkIfOpenShift(config)
+	Helper = *helper
 }
 
 func registerLogger(nameForLogger string) {
-	logger, ok := loggers[nameForLogger]
-	if !ok {
-		logger = log.Log.WithName(nameForLogger)
-		loggers[nameForLogger] = logger
+	loggersMutex.Lock()
+	defer loggersMutex.Unlock()
+	if _, ok := loggers[nameForLogger]; !ok {
+		loggers[nameForLogger] = log.Log.WithName(nameForLogger)
 	}
 }
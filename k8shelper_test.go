@@ -0,0 +1,25 @@
+package framework
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"testing"
+)
+
+func TestRecordEventOnlyRecordsWithExplicitRecorder(t *testing.T) {
+	secret := &corev1.Secret{}
+
+	// no recorder is set by default so nothing is recorded
+	K8SHelper{}.RecordEvent(secret, corev1.EventTypeNormal, "Ready", "ready")
+
+	recorder := record.NewFakeRecorder(1)
+	K8SHelper{Recorder: recorder}.RecordEvent(secret, corev1.EventTypeNormal, "Ready", "ready")
+	select {
+	case event := <-recorder.Events:
+		if event != "Normal Ready ready" {
+			t.Errorf("expected event to be recorded, got '%s'", event)
+		}
+	default:
+		t.Error("expected event to be recorded using the explicitly set recorder")
+	}
+}
@@ -0,0 +1,81 @@
+package framework
+
+import (
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"time"
+)
+
+// ControllerOptions configures how the controller created by RegisterNewConfiguredReconciler, and its GenericReconciler,
+// process Resources. The zero value yields the same behavior as RegisterNewReconciler.
+type ControllerOptions struct {
+	// MaxConcurrentReconciles is the maximum number of Resources the controller reconciles concurrently. Defaults to 1.
+	MaxConcurrentReconciles int
+	// RateLimiter, if set, computes the delay after which a Resource is reconciled again when its reconcile fails or when it
+	// needs to be requeued, instead of the default per-item exponential back-off. Since the controller's own rate limiter
+	// cannot be replaced, the reconciler emulates it by requeuing Resources after the computed delay, which requires failed
+	// reconciles to be reported as successful to the controller: errors are then logged by the reconciler but are neither
+	// logged by controller-runtime nor counted in its reconcile error metrics, failed reconciles being counted as requeued.
+	RateLimiter workqueue.RateLimiter
+	// ResyncPeriod, if positive, is the period after which successfully reconciled Resources are reconciled again, even if
+	// neither they nor their dependents changed. Resources which don't exist anymore are not resynced.
+	ResyncPeriod time.Duration
+	// Namespaces, if not empty, restricts the controller to the Resources in these namespaces, the other ones being ignored
+	Namespaces []string
+	// ReconcileTimeout, if positive, bounds the duration of each reconcile, the context passed to the ContextAwareResources and
+	// ContextAwareDependentResources being cancelled when it expires
+	ReconcileTimeout time.Duration
+}
+
+// handles determines whether Resources in the specified namespace are processed according to these ControllerOptions
+func (o ControllerOptions) handles(namespace string) bool {
+	if len(o.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range o.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// predicates returns the predicates to apply to the watch on the primary resource so that events on Resources outside of the
+// handled namespaces are filtered out
+func (o ControllerOptions) predicates() []predicate.Predicate {
+	if len(o.Namespaces) == 0 {
+		return nil
+	}
+	return []predicate.Predicate{predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return e.Meta != nil && o.handles(e.Meta.GetNamespace())
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return e.Meta != nil && o.handles(e.Meta.GetNamespace())
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.MetaNew != nil && o.handles(e.MetaNew.GetNamespace())
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return e.Meta != nil && o.handles(e.Meta.GetNamespace())
+		},
+	}}
+}
+
+// schedule adjusts the result of reconciling the specified request according to the RateLimiter and ResyncPeriod of these
+// ControllerOptions, returning whether the error, if any, was handled by the RateLimiter. Resources are only resynced if they
+// were found on the cluster.
+func (o ControllerOptions) schedule(request reconcile.Request, result reconcile.Result, err error, found bool) (reconcile.Result, bool) {
+	if o.RateLimiter != nil {
+		if err != nil || (result.Requeue && result.RequeueAfter <= 0) {
+			return reconcile.Result{RequeueAfter: o.RateLimiter.When(request)}, err != nil
+		}
+		o.RateLimiter.Forget(request)
+	}
+	if found && err == nil && !result.Requeue && result.RequeueAfter <= 0 && o.ResyncPeriod > 0 {
+		result.RequeueAfter = o.ResyncPeriod
+	}
+	return result, false
+}
@@ -0,0 +1,28 @@
+package framework
+
+import (
+	"halkyon.io/api/v1beta1"
+)
+
+// OutputsPublisher is an optional interface that Resources can implement to publish outputs (e.g. connection endpoint, secret
+// name, port) in their status so that other resources binding to them can discover them. Since v1beta1.Status only records the
+// conditions of dependents, such Resources record their outputs in a dedicated field of their own status using SetOutputs. The
+// framework retrieves the outputs when computing the status of the Resource, i.e. once its dependents have been successfully
+// created or updated, and records them as part of the status update it already performs.
+type OutputsPublisher interface {
+	// GetOutputs returns the current outputs of this Resource, as name / value pairs
+	GetOutputs() ([]v1beta1.NameValuePair, error)
+	// SetOutputs records the specified outputs in the status of this Resource, returning whether the status changed and
+	// therefore needs to be updated on the cluster
+	SetOutputs(outputs []v1beta1.NameValuePair) (changed bool)
+}
+
+// publishOutputs records the current outputs of the specified OutputsPublisher in its status, returning whether the status
+// changed
+func publishOutputs(publisher OutputsPublisher) (bool, error) {
+	outputs, err := publisher.GetOutputs()
+	if err != nil {
+		return false, err
+	}
+	return publisher.SetOutputs(outputs), nil
+}
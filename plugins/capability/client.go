@@ -1,13 +1,16 @@
 package capability
 
 import (
+	"context"
 	"encoding/gob"
 	"fmt"
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
 	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/util"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/errors"
@@ -15,6 +18,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Plugin is the operator-facing interface that can be interacted with in Halkyon
@@ -34,21 +42,100 @@ type Plugin interface {
 	Kill()
 	// CheckValidity checks that the specified capability is valid according to the Plugin's requirements
 	CheckValidity(in *halkyon.Capability) error
+	// OnDelete calls the Plugin's deletion hook, if any, with the last known state of the specified capability being deleted
+	OnDelete(owner *halkyon.Capability) error
+	// OnFinalize calls the Plugin's finalization hook, if any, with the last known state of the specified capability being
+	// deleted, right before its finalizer is removed
+	OnFinalize(owner *halkyon.Capability) error
+	// GetOutputs retrieves the capability-level outputs (e.g. connection endpoint, secret name, port) the Plugin provides for the
+	// specified capability, if any
+	GetOutputs(owner *halkyon.Capability) ([]v1beta1.NameValuePair, error)
 }
 
+// CapabilityFinalizer is the finalizer hosts add to Capabilities so that plugins get a chance to clean up when they're deleted
+const CapabilityFinalizer = "capability.halkyon.io/plugin-hooks"
+
+// Finalize calls the OnDelete then OnFinalize hooks of the specified Plugin for the given capability being deleted. Hosts are
+// expected to call it when finalizing Capabilities and to only remove the CapabilityFinalizer if it succeeds.
+func Finalize(p Plugin, owner *halkyon.Capability) error {
+	if err := p.OnDelete(owner); err != nil {
+		return fmt.Errorf("'%s' plugin deletion hook failed for '%s' capability: %v", p.Name(), owner.Name, err)
+	}
+	if err := p.OnFinalize(owner); err != nil {
+		return fmt.Errorf("'%s' plugin finalization hook failed for '%s' capability: %v", p.Name(), owner.Name, err)
+	}
+	return nil
+}
+
+// TypeInfo records information about a CapabilityType supported by a Plugin. Each of the supported Versions is either a version
+// or a range of versions as supported by util.ParseVersionRange. A TypeInfo without any version supports all versions.
+// Parameters optionally declares the schema of the parameters the type accepts, in which case the host validates and defaults
+// Capabilities' parameters before calling the plugin.
 type TypeInfo struct {
-	Type     halkyon.CapabilityType
-	Versions []string
+	Type       halkyon.CapabilityType
+	Versions   []string
+	Parameters []ParameterSchema
+}
+
+// Supports checks whether the specified version (or range of versions), as requested by a Capability, is supported according to
+// this TypeInfo. An empty version is always supported.
+func (t TypeInfo) Supports(version string) (bool, error) {
+	if len(version) == 0 || len(t.Versions) == 0 {
+		return true, nil
+	}
+	for _, supported := range t.Versions {
+		if supported == version {
+			return true, nil
+		}
+	}
+	requested, err := util.ParseVersionRange(version)
+	if err != nil {
+		return false, err
+	}
+	for _, supported := range t.Versions {
+		if r, err := util.ParseVersionRange(supported); err == nil && r.Overlaps(requested) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// overlaps checks whether some versions are supported by both this TypeInfo and the specified one. Versions that cannot be
+// parsed only overlap with identical versions.
+func (t TypeInfo) overlaps(other TypeInfo) bool {
+	if len(t.Versions) == 0 || len(other.Versions) == 0 {
+		return true
+	}
+	for _, version := range other.Versions {
+		if supported, err := t.Supports(version); supported && err == nil {
+			return true
+		}
+	}
+	return false
 }
 
+// PluginClient is the host-side Plugin implementation, forwarding calls to the plugin over RPC. A PluginClient is safe for
+// concurrent use: it doesn't hold any request-scoped state, which is carried by each PluginRequest instead, and its caches are
+// lock-free.
 type PluginClient struct {
-	client      *rpc.Client
-	name        string
-	owner       *halkyon.Capability
-	gpClient    *plugin.Client
-	capCategory *halkyon.CapabilityCategory
-	capTypes    *[]TypeInfo
-	log         logr.Logger
+	client   *rpc.Client
+	broker   *plugin.MuxBroker
+	name     string
+	gpClient *plugin.Client
+	log      logr.Logger
+	metadata *pluginMetadata
+	// calls is used to track in-flight calls so that they can be drained
+	calls *sync.RWMutex
+}
+
+// pluginMetadata caches the immutable information about a plugin
+type pluginMetadata struct {
+	category atomic.Value // halkyon.CapabilityCategory
+	types    atomic.Value // []TypeInfo
+}
+
+func newPluginClient(name string, client *rpc.Client) *PluginClient {
+	return &PluginClient{name: name, client: client, metadata: &pluginMetadata{}, calls: &sync.RWMutex{}}
 }
 
 var _ Plugin = &PluginClient{}
@@ -69,125 +156,280 @@ func (p *PluginClient) recordGoPluginClient(client *plugin.Client) {
 	p.gpClient = client
 }
 
+// GetCategory retrieves the category of the plugin, which is only queried once. Concurrent first calls might query the plugin
+// several times but always yield the same result.
 func (p *PluginClient) GetCategory() halkyon.CapabilityCategory {
-	if p.capCategory == nil {
-		var cat halkyon.CapabilityCategory
-		p.call("GetCategory", emptyGVK, &cat)
-		p.capCategory = &cat
+	if cat, ok := p.metadata.category.Load().(halkyon.CapabilityCategory); ok {
+		return cat
+	}
+	var cat halkyon.CapabilityCategory
+	if err := p.call(nil, "GetCategory", emptyGVK, &cat); err == nil {
+		p.metadata.category.Store(cat)
 	}
-	return *p.capCategory
+	return cat
 }
 
+// GetTypes retrieves the types supported by the plugin, which are only queried once. Concurrent first calls might query the
+// plugin several times but always yield the same result.
 func (p *PluginClient) GetTypes() []TypeInfo {
-	if p.capTypes == nil {
-		res := []TypeInfo{}
-		p.call("GetTypes", emptyGVK, &res)
-		p.capTypes = &res
+	if types, ok := p.metadata.types.Load().([]TypeInfo); ok {
+		return types
 	}
-	return *p.capTypes
+	res := []TypeInfo{}
+	if err := p.call(nil, "GetTypes", emptyGVK, &res); err == nil {
+		p.metadata.types.Store(res)
+	}
+	return res
 }
 
 func (p *PluginClient) Kill() {
+	if p.gpClient == nil {
+		// in-process plugin: only close the RPC connection
+		_ = p.client.Close()
+		return
+	}
 	p.gpClient.Kill()
 }
 
 func (p *PluginClient) ReadyFor(owner *halkyon.Capability) []framework.DependentResource {
-	client := &PluginClient{
-		client: p.client,
-		name:   p.name,
-		log:    p.log,
-		owner:  owner,
-	}
+	defaulted := withDefaults(p, owner)
 	resourcesTypes := []schema.GroupVersionKind{}
-	client.call("GetDependentResourceTypes", emptyGVK, &resourcesTypes)
+	p.call(defaulted, "GetDependentResourceTypes", emptyGVK, &resourcesTypes)
 	depRes := make([]framework.DependentResource, 0, len(resourcesTypes))
 	for _, rt := range resourcesTypes {
-		depRes = append(depRes, &PluginDependentResource{client: client, gvk: rt, owner: owner})
+		depRes = append(depRes, &PluginDependentResource{client: p, gvk: rt, owner: defaulted})
 	}
 	return depRes
 }
 
 func (p *PluginClient) CheckValidity(in *halkyon.Capability) error {
-	client := &PluginClient{
-		client: p.client,
-		name:   p.name,
-		log:    p.log,
-		owner:  in,
+	// validate the version and parameters on the host first so that the plugin is only called with valid capabilities
+	errs := make([]error, 0, 2)
+	if err := p.checkVersion(in); err != nil {
+		errs = append(errs, err)
 	}
-	res := []string{}
-	client.call("CheckValidity", emptyGVK, &res)
-	errNb := len(res)
-	if errNb > 0 {
-		errs := make([]error, errNb)
-		for _, msg := range res {
-			errs = append(errs, fmt.Errorf(msg))
+	if typeInfo, ok := typeInfoFor(p, in.Spec.Type); ok {
+		if err := typeInfo.ValidateParameters(in.Spec.Parameters); err != nil {
+			errs = append(errs, err)
 		}
+	}
+	if len(errs) > 0 {
 		return errors.NewAggregate(errs)
 	}
+
+	res := []string{}
+	if err := p.call(withDefaults(p, in), "CheckValidity", emptyGVK, &res); err != nil {
+		return err
+	}
+	for _, msg := range res {
+		errs = append(errs, fmt.Errorf("%s", msg))
+	}
+	return errors.NewAggregate(errs)
+}
+
+func (p *PluginClient) OnDelete(owner *halkyon.Capability) error {
+	called := false
+	return p.call(withDefaults(p, owner), "OnDelete", emptyGVK, &called)
+}
+
+func (p *PluginClient) OnFinalize(owner *halkyon.Capability) error {
+	called := false
+	return p.call(withDefaults(p, owner), "OnFinalize", emptyGVK, &called)
+}
+
+func (p *PluginClient) GetOutputs(owner *halkyon.Capability) ([]v1beta1.NameValuePair, error) {
+	outputs := []v1beta1.NameValuePair{}
+	if err := p.call(withDefaults(p, owner), "GetOutputs", emptyGVK, &outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// checkVersion checks that the version requested by the specified Capability is supported by this Plugin
+func (p *PluginClient) checkVersion(in *halkyon.Capability) error {
+	typeInfo, ok := typeInfoFor(p, in.Spec.Type)
+	if !ok {
+		return fmt.Errorf("'%s' plugin doesn't support capability type '%s'", p.name, in.Spec.Type)
+	}
+	supported, err := typeInfo.Supports(in.Spec.Version)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fmt.Errorf("version '%s' of '%s' is not supported by '%s' plugin, supported versions: %s", in.Spec.Version, in.Spec.Type, p.name, strings.Join(typeInfo.Versions, ", "))
+	}
 	return nil
 }
 
+// PluginConfig represents the configuration the host uses to set up and interact with a Plugin.
+type PluginConfig struct {
+	// AllowedGVKs lists the GroupVersionKinds the plugin is allowed to access on the cluster using its KubeClient. Defaults to
+	// none, meaning that the plugin cannot access the cluster.
+	AllowedGVKs []schema.GroupVersionKind
+	// LogLevel is the level at and above which the plugin's logs are re-emitted via the host's logger. Defaults to hclog.Info.
+	LogLevel hclog.Level
+	// Registry is the Registry in which the plugin is registered. Defaults to DefaultRegistry.
+	Registry *Registry
+	// Helper is the K8SHelper used to access the cluster on behalf of the plugin. Defaults to framework.Helper, which is looked
+	// up when the cluster is accessed so that it can be initialized after the plugin is started.
+	Helper *framework.K8SHelper
+}
+
 // NewPlugin creates the infrastructure required for the host (the operator) to be able to call the plugin binary which path is
 // given, setting up a logger that can be used to output information in the operator logs. The new Plugin is queried and its
 // supported category/type pairs are registered so that when a Capability requiring one of these pairs is created, the operator
 // can delegate to the appropriate plugin. The RPC server and client are also started using the Handshake configuration.
 func NewPlugin(path string, log logr.Logger) (Plugin, error) {
+	return NewConfiguredPlugin(path, log, PluginConfig{})
+}
+
+// NewConfiguredPlugin creates a new Plugin as NewPlugin does, using the specified PluginConfig.
+func NewConfiguredPlugin(path string, log logr.Logger, config PluginConfig) (Plugin, error) {
+	p, err := startPlugin(path, log, config)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't start plugin '%s': %w", path, err)
+	}
+
+	if err := config.registry().Register(p); err != nil {
+		p.Kill()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// startPlugin launches the plugin binary which path is given and connects to it, without registering it
+func startPlugin(path string, log logr.Logger, config PluginConfig) (*PluginClient, error) {
 	name := filepath.Base(path)
 
-	// We're a host. Start by launching the plugin process.
+	// We're a host. Start by launching the plugin process, telling it which log level to use.
+	level := levelOrDefault(config.LogLevel)
+	cmd := exec.Command(path)
+	cmd.Env = []string{fmt.Sprintf("%s=%s", PluginLogLevelEnvVar, levelNames[level])}
+	// go-plugin appends the host's environment to cmd.Env, so a level set in the host's environment would override the
+	// configured one: the variable is only meant for plugins, so remove it from the host's environment before starting them
+	if value, ok := os.LookupEnv(PluginLogLevelEnvVar); ok {
+		log.Info(fmt.Sprintf("ignoring '%s' %s environment variable, plugins use the level configured in their PluginConfig", value, PluginLogLevelEnvVar))
+		if err := os.Unsetenv(PluginLogLevelEnvVar); err != nil {
+			return nil, err
+		}
+	}
 	client := plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig: Handshake,
 		Plugins:         map[string]plugin.Plugin{name: &GoPluginPlugin{name: name}},
-		Cmd:             exec.Command(path),
-		Logger: hclog.New(&hclog.LoggerOptions{
-			Output: hclog.DefaultOutput,
-			Level:  hclog.Trace,
-			Name:   name,
-		}),
+		Cmd:             cmd,
+		Logger:          newLogrLogger(log, level),
 	})
 
 	// Connect via RPC
 	rpcClient, err := client.Client()
 	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		client.Kill()
+		return nil, err
 	}
 
 	// Request the plugin
 	raw, err := rpcClient.Dispense(name)
 	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		client.Kill()
+		return nil, err
 	}
 	p := raw.(*PluginClient)
 	p.log = log
 	p.recordGoPluginClient(client)
-
-	register(p)
+	p.serveKubeProxy(config)
 
 	return p, nil
 }
 
-func (p *PluginClient) call(method string, targetDependentType schema.GroupVersionKind, result interface{}, underlying ...runtime.Object) {
-	request := p.createRequest(method, targetDependentType, underlying...)
-	p.callWithRequest(method, request, result)
+func (c PluginConfig) registry() *Registry {
+	if c.Registry == nil {
+		return DefaultRegistry
+	}
+	return c.Registry
+}
+
+func (c PluginConfig) helper() *framework.K8SHelper {
+	if c.Helper == nil {
+		return &framework.Helper
+	}
+	return c.Helper
 }
 
-func (p *PluginClient) callWithRequest(method string, request PluginRequest, result interface{}) {
-	err := p.client.Call("Plugin."+method, request, result)
+// drainAndKill waits for the calls in flight to complete, for at most the specified duration, before killing this Plugin. Calls
+// issued while draining wait for the Plugin to be killed and then fail.
+func (p *PluginClient) drainAndKill(timeout time.Duration) {
+	drained := make(chan struct{})
+	killed := make(chan struct{})
+	go func() {
+		p.calls.Lock()
+		close(drained)
+		<-killed
+		p.calls.Unlock()
+	}()
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		p.log.Info(fmt.Sprintf("'%s' plugin still had calls in flight after %v, killing it anyway", p.name, timeout))
+	}
+	p.Kill()
+	close(killed)
+}
+
+// serveKubeProxy serves a KubeProxyServer configured using the specified PluginConfig over the go-plugin broker and asks the
+// plugin to connect to it
+func (p *PluginClient) serveKubeProxy(config PluginConfig) {
+	id := p.broker.NextId()
+	go p.broker.AcceptAndServe(id, newKubeProxyServer(p.name, config, p.log))
+	connected := false
+	if err := p.client.Call("Plugin.ConnectKubeProxy", id, &connected); err != nil {
+		p.log.Error(err, fmt.Sprintf("couldn't provide cluster access to %s plugin", p.name))
+	}
+}
+
+// call calls the specified method on the plugin on behalf of the specified owner, which can be nil for calls which are not
+// related to any owner
+func (p *PluginClient) call(owner framework.SerializableResource, method string, targetDependentType schema.GroupVersionKind, result interface{}, underlying ...runtime.Object) error {
+	return p.callWithContext(context.Background(), owner, method, targetDependentType, result, underlying...)
+}
+
+// callWithContext calls the specified method on the plugin, giving up waiting for the result if the specified context is done.
+// The plugin isn't notified, though, so it will still complete the call.
+func (p *PluginClient) callWithContext(ctx context.Context, owner framework.SerializableResource, method string, targetDependentType schema.GroupVersionKind, result interface{}, underlying ...runtime.Object) error {
+	request := p.createRequest(owner, method, targetDependentType, underlying...)
+	return p.callWithRequest(ctx, method, request, result)
+}
+
+func (p *PluginClient) callWithRequest(ctx context.Context, method string, request PluginRequest, result interface{}) error {
+	p.calls.RLock()
+	defer p.calls.RUnlock()
+	var err error
+	if ctx.Done() == nil {
+		err = p.client.Call("Plugin."+method, request, result)
+	} else {
+		// decode into a separate value so that an abandoned call cannot write to result after we returned
+		reply := reflect.New(reflect.TypeOf(result).Elem())
+		call := p.client.Go("Plugin."+method, request, reply.Interface(), make(chan *rpc.Call, 1))
+		select {
+		case <-call.Done:
+			if err = call.Error; err == nil {
+				reflect.ValueOf(result).Elem().Set(reply.Elem())
+			}
+		case <-ctx.Done():
+			err = fmt.Errorf("gave up waiting for %s plugin: %w", p.name, ctx.Err())
+		}
+	}
 	if err != nil {
 		p.log.Error(err, fmt.Sprintf("error calling %s on %s plugin", method, p.name))
 	}
+	return err
 }
 
-func (p *PluginClient) createRequest(method string, targetDependentType schema.GroupVersionKind, underlying ...runtime.Object) PluginRequest {
+func (p *PluginClient) createRequest(owner framework.SerializableResource, method string, targetDependentType schema.GroupVersionKind, underlying ...runtime.Object) PluginRequest {
 	if len(underlying) > 1 {
 		p.log.Error(fmt.Errorf("error calling %s on %s plugin", method, p.name), fmt.Sprintf("call only accepts one extra argument, was given %v", underlying))
 	}
-	request := PluginRequest{}
-	if p.owner != nil {
-		request.Owner = p.owner
-	}
+	request := PluginRequest{Owner: owner}
 	if !targetDependentType.Empty() {
 		request.Target = targetDependentType
 	}
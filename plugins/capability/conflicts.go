@@ -0,0 +1,148 @@
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	"halkyon.io/api/capability-info/v1beta1"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/operator-framework/util"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ShadowedPluginsAnnotation is the annotation on CapabilityInfos recording, as JSON, the plugins which also support the
+	// associated category/type pair but lost to the registered ones according to the Registry's ConflictResolution. Its value
+	// is a list of ShadowedPlugin.
+	ShadowedPluginsAnnotation = "halkyon.io/shadowed-plugins"
+	// PluginShadowedReason is the reason of the warning events recorded on a CapabilityInfo for each of the plugins shadowed
+	// for its category/type pair, since the CapabilityInfo API doesn't provide a status field to report them in
+	PluginShadowedReason = "PluginShadowed"
+)
+
+// ConflictPolicy determines which Plugin is kept when several Plugins support overlapping versions of the same category/type
+// pair
+type ConflictPolicy string
+
+const (
+	// FirstWins keeps the Plugin which was registered first
+	FirstWins ConflictPolicy = "first-wins"
+	// HighestVersionWins keeps the Plugin supporting the highest versions, the first registered one winning ties
+	HighestVersionWins ConflictPolicy = "highest-version-wins"
+	// PriorityWins keeps the Plugin with the highest priority, the first registered one winning ties
+	PriorityWins ConflictPolicy = "priority"
+	// FailFast refuses to register Plugins conflicting with already registered ones
+	FailFast ConflictPolicy = "fail-fast"
+)
+
+// ConflictResolution configures how a Registry resolves conflicts between Plugins
+type ConflictResolution struct {
+	// Policy is the ConflictPolicy to apply, defaults to FirstWins
+	Policy ConflictPolicy `json:"policy,omitempty"`
+	// Priorities records the priority of Plugins, identified by their name, when using the PriorityWins policy. Plugins without
+	// an explicit priority have a priority of 0.
+	Priorities map[string]int `json:"priorities,omitempty"`
+}
+
+// ShadowedPlugin records a Plugin which lost a conflict for a category/type pair, as published in the ShadowedPluginsAnnotation
+type ShadowedPlugin struct {
+	Plugin   string   `json:"plugin"`
+	Versions []string `json:"versions,omitempty"`
+}
+
+// LoadConflictResolution loads a ConflictResolution from the specified YAML (or JSON) file, e.g.:
+//
+//	policy: priority
+//	priorities:
+//	  postgres-plugin: 10
+//	  legacy-postgres-plugin: 1
+func LoadConflictResolution(path string) (ConflictResolution, error) {
+	resolution := ConflictResolution{}
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return resolution, err
+	}
+	if err := yaml.Unmarshal(bytes, &resolution); err != nil {
+		return resolution, fmt.Errorf("invalid conflict resolution configuration in '%s': %v", path, err)
+	}
+	if err := resolution.validate(); err != nil {
+		return resolution, fmt.Errorf("invalid conflict resolution configuration in '%s': %v", path, err)
+	}
+	return resolution, nil
+}
+
+func (c ConflictResolution) validate() error {
+	switch c.Policy {
+	case "", FirstWins, HighestVersionWins, PriorityWins, FailFast:
+		return nil
+	default:
+		return fmt.Errorf("unknown conflict policy '%s'", c.Policy)
+	}
+}
+
+// wins determines whether the specified challenger Plugin wins over the registered one for the given CapabilityType
+func (c ConflictResolution) wins(capType halkyon.CapabilityType, challenger, registered Plugin) bool {
+	switch c.Policy {
+	case HighestVersionWins:
+		challengerInfo, _ := typeInfoFor(challenger, capType)
+		registeredInfo, _ := typeInfoFor(registered, capType)
+		challengerVersion, ok := highestVersion(challengerInfo)
+		if !ok {
+			return false
+		}
+		registeredVersion, ok := highestVersion(registeredInfo)
+		return !ok || challengerVersion.Compare(registeredVersion) > 0
+	case PriorityWins:
+		return c.Priorities[challenger.Name()] > c.Priorities[registered.Name()]
+	default:
+		return false
+	}
+}
+
+// highestVersion returns the highest version bound among the versions supported by the specified TypeInfo, if any
+func highestVersion(typeInfo TypeInfo) (util.Version, bool) {
+	var highest util.Version
+	found := false
+	for _, version := range typeInfo.Versions {
+		r, err := util.ParseVersionRange(version)
+		if err != nil {
+			continue
+		}
+		if v, ok := r.Highest(); ok && (!found || v.Compare(highest) > 0) {
+			highest, found = v, true
+		}
+	}
+	return highest, found
+}
+
+// reportShadowed records a PluginShadowedReason event on the specified CapabilityInfo, using the Recorder of the Registry's
+// K8SHelper, for each of the specified Plugins shadowed for the given CapabilityType
+func (r *Registry) reportShadowed(info *v1beta1.CapabilityInfo, capType halkyon.CapabilityType, shadowed []Plugin) {
+	if len(shadowed) == 0 {
+		return
+	}
+	// objects returned by typed clients don't carry their type, which is needed to reference them in events
+	object := *info
+	object.APIVersion, object.Kind = halkyon.SchemeGroupVersion.String(), "CapabilityInfo"
+	helper := r.GetHelper()
+	for _, p := range shadowed {
+		typeInfo, _ := typeInfoFor(p, capType)
+		helper.RecordEvent(&object, corev1.EventTypeWarning, PluginShadowedReason, fmt.Sprintf("'%s' plugin supporting versions '%s' of type '%s' is shadowed by a conflicting plugin",
+			p.Name(), v1beta1.VersionsAsString(typeInfo.Versions...), capType))
+	}
+}
+
+// shadowedAnnotation computes the value of the ShadowedPluginsAnnotation for the specified shadowed Plugins and CapabilityType
+func shadowedAnnotation(capType halkyon.CapabilityType, shadowed ...Plugin) (string, error) {
+	if len(shadowed) == 0 {
+		return "", nil
+	}
+	infos := make([]ShadowedPlugin, 0, len(shadowed))
+	for _, p := range shadowed {
+		typeInfo, _ := typeInfoFor(p, capType)
+		infos = append(infos, ShadowedPlugin{Plugin: p.Name(), Versions: typeInfo.Versions})
+	}
+	bytes, err := json.Marshal(infos)
+	return string(bytes), err
+}
@@ -0,0 +1,340 @@
+package capability
+
+import (
+	"encoding/json"
+	capinfo "halkyon.io/api/capability-info/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"io/ioutil"
+	"k8s.io/client-go/tools/record"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"strings"
+	"testing"
+)
+
+func names(plugins []Plugin) []string {
+	result := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		result = append(result, p.Name())
+	}
+	return result
+}
+
+func TestConflictPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution ConflictResolution
+		first      *stubPlugin
+		second     *stubPlugin
+		winner     string
+		shadowed   []string
+		fails      bool
+	}{
+		{
+			name:       "first wins",
+			resolution: ConflictResolution{Policy: FirstWins},
+			first:      newStubPlugin("a", "database", "postgres", "10", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "11", "12"),
+			winner:     "a",
+			shadowed:   []string{"b"},
+		},
+		{
+			name:       "default policy is first wins",
+			resolution: ConflictResolution{},
+			first:      newStubPlugin("a", "database", "postgres", "10", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "11", "12"),
+			winner:     "a",
+			shadowed:   []string{"b"},
+		},
+		{
+			name:       "highest version wins",
+			resolution: ConflictResolution{Policy: HighestVersionWins},
+			first:      newStubPlugin("a", "database", "postgres", "10", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "11", "12"),
+			winner:     "b",
+			shadowed:   []string{"a"},
+		},
+		{
+			name:       "lower version loses",
+			resolution: ConflictResolution{Policy: HighestVersionWins},
+			first:      newStubPlugin("a", "database", "postgres", ">=11, <13"),
+			second:     newStubPlugin("b", "database", "postgres", "11"),
+			winner:     "a",
+			shadowed:   []string{"b"},
+		},
+		{
+			name:       "same highest version keeps first",
+			resolution: ConflictResolution{Policy: HighestVersionWins},
+			first:      newStubPlugin("a", "database", "postgres", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "10", "11"),
+			winner:     "a",
+			shadowed:   []string{"b"},
+		},
+		{
+			name:       "highest priority wins",
+			resolution: ConflictResolution{Policy: PriorityWins, Priorities: map[string]int{"a": 1, "b": 10}},
+			first:      newStubPlugin("a", "database", "postgres", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "11"),
+			winner:     "b",
+			shadowed:   []string{"a"},
+		},
+		{
+			name:       "same priority keeps first",
+			resolution: ConflictResolution{Policy: PriorityWins, Priorities: map[string]int{"other": 10}},
+			first:      newStubPlugin("a", "database", "postgres", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "11"),
+			winner:     "a",
+			shadowed:   []string{"b"},
+		},
+		{
+			name:       "fail fast",
+			resolution: ConflictResolution{Policy: FailFast},
+			first:      newStubPlugin("a", "database", "postgres", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "11"),
+			winner:     "a",
+			fails:      true,
+		},
+		{
+			name:       "no conflict without overlapping versions",
+			resolution: ConflictResolution{Policy: FailFast},
+			first:      newStubPlugin("a", "database", "postgres", "10", "11"),
+			second:     newStubPlugin("b", "database", "postgres", "12"),
+			winner:     "a",
+		},
+		{
+			name:       "no conflict with other category",
+			resolution: ConflictResolution{Policy: FailFast},
+			first:      newStubPlugin("a", "database", "postgres", "11"),
+			second:     newStubPlugin("b", "cache", "postgres", "11"),
+			winner:     "a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewConfiguredRegistry(log.Log, tt.resolution)
+			registry.SetOffline(true)
+			shadowedEvents := []string{}
+			registry.Subscribe(func(event RegistryEvent) {
+				if event.Type == PluginShadowed {
+					shadowedEvents = append(shadowedEvents, event.Plugin.Name())
+				}
+			})
+
+			if err := registry.Register(tt.first); err != nil {
+				t.Fatal(err)
+			}
+			err := registry.Register(tt.second)
+			if tt.fails != (err != nil) {
+				t.Fatalf("expected registration to fail: %v, got: %v", tt.fails, err)
+			}
+
+			winner, err := registry.GetPluginForVersion("database", "postgres", "11")
+			if err != nil || winner.Name() != tt.winner {
+				t.Errorf("expected '%s' plugin to handle version 11, got %v (%v)", tt.winner, winner, err)
+			}
+			if shadowed := names(registry.Shadowed("database", "postgres")); !reflect.DeepEqual(shadowed, append([]string{}, tt.shadowed...)) {
+				t.Errorf("expected %v plugins to be shadowed, got %v", tt.shadowed, shadowed)
+			}
+			if !reflect.DeepEqual(shadowedEvents, append([]string{}, tt.shadowed...)) {
+				t.Errorf("expected listeners to be notified that %v plugins are shadowed, got %v", tt.shadowed, shadowedEvents)
+			}
+			if tt.fails {
+				if plugins := registry.Plugins(); len(plugins) != 1 {
+					t.Errorf("expected refused plugin not to be registered, got %v", names(plugins))
+				}
+			}
+		})
+	}
+}
+
+func TestShadowedPluginsAreReported(t *testing.T) {
+	infos := newFakeCapabilityInfos()
+	recorder := record.NewFakeRecorder(10)
+	registry := NewRegistry(log.Log)
+	registry.SetHelper(&framework.K8SHelper{Recorder: recorder})
+	registry.SetCapabilityInfoClient(infos)
+
+	if err := registry.Register(newStubPlugin("a", "database", "postgres", "10", "11")); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(newStubPlugin("b", "database", "postgres", "11", "12")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, ok := infos.get("database-postgres")
+	if !ok {
+		t.Fatal("expected CapabilityInfo to be published")
+	}
+	shadowed := []ShadowedPlugin{}
+	if err := json.Unmarshal([]byte(info.Annotations[ShadowedPluginsAnnotation]), &shadowed); err != nil {
+		t.Fatalf("invalid %s annotation: %v", ShadowedPluginsAnnotation, err)
+	}
+	if expected := []ShadowedPlugin{{Plugin: "b", Versions: []string{"11", "12"}}}; !reflect.DeepEqual(shadowed, expected) {
+		t.Errorf("expected %v shadowed plugins, got %v", expected, shadowed)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, "Warning "+PluginShadowedReason+" 'b' plugin") {
+			t.Errorf("expected warning event reporting that 'b' plugin is shadowed, got '%s'", event)
+		}
+	default:
+		t.Error("expected an event to report the shadowed plugin")
+	}
+}
+
+func TestShadowedPluginsArePromotedOnUnregister(t *testing.T) {
+	infos := newFakeCapabilityInfos()
+	recorder := record.NewFakeRecorder(10)
+	registry := NewRegistry(log.Log)
+	registry.SetHelper(&framework.K8SHelper{Recorder: recorder})
+	registry.SetCapabilityInfoClient(infos)
+	a := newStubPlugin("a", "database", "postgres", "10", "11")
+	b := newStubPlugin("b", "database", "postgres", "11", "12")
+	c := newStubPlugin("c", "database", "postgres", "12")
+	for _, p := range []Plugin{a, b, c} {
+		if err := registry.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if shadowed := names(registry.Shadowed("database", "postgres")); !reflect.DeepEqual(shadowed, []string{"b"}) {
+		t.Fatalf("expected 'b' plugin to be shadowed, got %v", shadowed)
+	}
+	drain(recorder)
+	events := []string{}
+	registry.Subscribe(func(event RegistryEvent) {
+		events = append(events, string(event.Type)+" "+event.Plugin.Name())
+	})
+
+	// b now only conflicts with c, which was registered first
+	registry.Unregister(a)
+	if shadowed := names(registry.Shadowed("database", "postgres")); !reflect.DeepEqual(shadowed, []string{"b"}) {
+		t.Errorf("expected 'b' plugin to still be shadowed by 'c' plugin, got %v", shadowed)
+	}
+	if expected := []string{"Unregistered a"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+	drain(recorder)
+
+	// b doesn't conflict with any plugin anymore
+	events = events[:0]
+	registry.Unregister(c)
+	if shadowed := registry.Shadowed("database", "postgres"); len(shadowed) != 0 {
+		t.Errorf("expected no plugin to be shadowed anymore, got %v", names(shadowed))
+	}
+	if p, err := registry.GetPluginForVersion("database", "postgres", "12"); err != nil || p != b {
+		t.Errorf("expected 'b' plugin to handle version 12 once promoted, got %v (%v)", p, err)
+	}
+	if expected := []string{"Unregistered c", "Registered b"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+	info, _ := infos.get("database-postgres")
+	if annotation, ok := info.Annotations[ShadowedPluginsAnnotation]; ok {
+		t.Errorf("expected %s annotation to be removed once no plugin is shadowed, got %s", ShadowedPluginsAnnotation, annotation)
+	}
+	if info.Spec.Versions != capinfo.VersionsAsString("11", "12") {
+		t.Errorf("expected CapabilityInfo to list the versions of the promoted plugin, got %s", info.Spec.Versions)
+	}
+	if reported := drain(recorder); len(reported) != 0 {
+		t.Errorf("didn't expect promoted plugin to be reported as shadowed, got %v", reported)
+	}
+}
+
+func TestShadowedPluginsArePromotedInOrder(t *testing.T) {
+	registry := NewRegistry(log.Log)
+	registry.SetOffline(true)
+	a := newStubPlugin("a", "database", "postgres", "11")
+	b := newStubPlugin("b", "database", "postgres", "11")
+	c := newStubPlugin("c", "database", "postgres", "11")
+	for _, p := range []Plugin{a, b, c} {
+		if err := registry.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry.Unregister(a)
+	if p, err := registry.GetPluginForVersion("database", "postgres", "11"); err != nil || p != b {
+		t.Errorf("expected first shadowed plugin to be promoted, got %v (%v)", p, err)
+	}
+	if shadowed := names(registry.Shadowed("database", "postgres")); !reflect.DeepEqual(shadowed, []string{"c"}) {
+		t.Errorf("expected 'c' plugin to still be shadowed, got %v", shadowed)
+	}
+
+	// unregistering a shadowed plugin doesn't change which plugin handles the pair
+	registry.Unregister(c)
+	if p, err := registry.GetPluginForVersion("database", "postgres", "11"); err != nil || p != b {
+		t.Errorf("expected 'b' plugin to still handle the pair, got %v (%v)", p, err)
+	}
+	if shadowed := registry.Shadowed("database", "postgres"); len(shadowed) != 0 {
+		t.Errorf("expected no plugin to be shadowed anymore, got %v", names(shadowed))
+	}
+}
+
+// drain returns the events recorded by the specified FakeRecorder since the last call
+func drain(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestOwnerLabelsOfSeveralInstances(t *testing.T) {
+	infos := newFakeCapabilityInfos()
+	for _, id := range []string{"operator-1", "operator-2"} {
+		registry := NewRegistry(log.Log)
+		registry.SetCapabilityInfoClient(infos)
+		registry.SetOwnerID(id)
+		if err := registry.Register(newStubPlugin("postgres-plugin", "database", "postgres", "11")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, _ := infos.get("database-postgres")
+	expected := map[string]string{ownerLabel("operator-1"): "true", ownerLabel("operator-2"): "true"}
+	if !reflect.DeepEqual(info.Labels, expected) {
+		t.Errorf("expected CapabilityInfo to be owned by both operator instances, got labels %v", info.Labels)
+	}
+}
+
+func TestLoadConflictResolution(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conflicts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	resolution, err := LoadConflictResolution(write("priority.yaml", "policy: priority\npriorities:\n  postgres-plugin: 10\n  legacy-postgres-plugin: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ConflictResolution{Policy: PriorityWins, Priorities: map[string]int{"postgres-plugin": 10, "legacy-postgres-plugin": 1}}
+	if !reflect.DeepEqual(resolution, expected) {
+		t.Errorf("expected %v, got %v", expected, resolution)
+	}
+
+	for name, content := range map[string]string{"unknown.yaml": "policy: last-wins\n", "invalid.yaml": "policy: [priority\n"} {
+		if _, err := LoadConflictResolution(write(name, content)); err == nil {
+			t.Errorf("expected loading %s to fail", name)
+		}
+	}
+	if _, err := LoadConflictResolution(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected loading missing file to fail")
+	}
+	if err := NewRegistry(log.Log).SetConflictResolution(ConflictResolution{Policy: "last-wins"}); err == nil {
+		t.Error("expected setting an unknown policy to fail")
+	}
+}
@@ -0,0 +1,292 @@
+package capability
+
+import (
+	"fmt"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/util"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"strings"
+)
+
+// ConformanceViolation describes a breach of the contract PluginResource implementations need to honor to work properly with
+// the plugins architecture.
+type ConformanceViolation struct {
+	// Capability is the name of the sample Capability which revealed the violation, if any
+	Capability string
+	// Dependent is the GroupVersionKind of the dependent which revealed the violation, if any
+	Dependent schema.GroupVersionKind
+	// Message describes the violation
+	Message string
+}
+
+func (c ConformanceViolation) Error() string {
+	msg := c.Message
+	if !c.Dependent.Empty() {
+		msg = fmt.Sprintf("%v dependent: %s", c.Dependent, msg)
+	}
+	if len(c.Capability) > 0 {
+		msg = fmt.Sprintf("'%s' capability: %s", c.Capability, msg)
+	}
+	return msg
+}
+
+// CheckConformance exercises the specified PluginResource against the given sample Capabilities and returns the contract
+// violations it found, if any. The specified scheme is used to check that dependents' types are known to the host.
+func CheckConformance(resource PluginResource, scheme *runtime.Scheme, samples ...*halkyon.Capability) []ConformanceViolation {
+	c := &conformanceChecker{resource: resource, scheme: scheme}
+	c.checkTypes()
+	for _, sample := range samples {
+		c.checkSample(sample)
+	}
+	return c.violations
+}
+
+type conformanceChecker struct {
+	resource   PluginResource
+	scheme     *runtime.Scheme
+	violations []ConformanceViolation
+}
+
+func (c *conformanceChecker) report(capability string, dependent schema.GroupVersionKind, format string, args ...interface{}) {
+	c.violations = append(c.violations, ConformanceViolation{
+		Capability: capability,
+		Dependent:  dependent,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// safely calls the specified function, reporting a violation if it panics and returning whether it completed normally
+func (c *conformanceChecker) safely(capability string, dependent schema.GroupVersionKind, method string, f func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.report(capability, dependent, "%s panicked: %v", method, r)
+			ok = false
+		}
+	}()
+	f()
+	return true
+}
+
+func (c *conformanceChecker) checkTypes() {
+	if len(c.resource.GetSupportedCategory()) == 0 {
+		c.report("", emptyGVK, "GetSupportedCategory must return a non-empty category")
+	}
+	types := c.resource.GetSupportedTypes()
+	if len(types) == 0 {
+		c.report("", emptyGVK, "GetSupportedTypes must return at least one type")
+	}
+	seen := make(map[halkyon.CapabilityType]bool, len(types))
+	for _, typeInfo := range types {
+		if len(typeInfo.Type) == 0 {
+			c.report("", emptyGVK, "GetSupportedTypes must not return empty types")
+			continue
+		}
+		key := typeKey(typeInfo.Type)
+		if seen[key] {
+			c.report("", emptyGVK, "GetSupportedTypes returned type '%s' several times", typeInfo.Type)
+		}
+		seen[key] = true
+		c.checkVersions(typeInfo)
+		c.checkParameters(typeInfo)
+	}
+}
+
+// checkVersions checks that the versions declared by the specified TypeInfo can be used to match Capabilities' versions
+func (c *conformanceChecker) checkVersions(typeInfo TypeInfo) {
+	versions := make(map[string]bool, len(typeInfo.Versions))
+	for _, version := range typeInfo.Versions {
+		if len(strings.TrimSpace(version)) == 0 {
+			c.report("", emptyGVK, "type '%s' declares an empty version, omit versions to support all of them", typeInfo.Type)
+			continue
+		}
+		if versions[version] {
+			c.report("", emptyGVK, "type '%s' declares version '%s' several times", typeInfo.Type, version)
+		}
+		versions[version] = true
+		if _, err := util.ParseVersionRange(version); err != nil {
+			c.report("", emptyGVK, "type '%s' declares an invalid version: %v", typeInfo.Type, err)
+		}
+	}
+}
+
+func (c *conformanceChecker) checkParameters(typeInfo TypeInfo) {
+	names := make(map[string]bool, len(typeInfo.Parameters))
+	for _, parameter := range typeInfo.Parameters {
+		if len(parameter.Name) == 0 {
+			c.report("", emptyGVK, "type '%s' declares a parameter without a name", typeInfo.Type)
+			continue
+		}
+		if names[parameter.Name] {
+			c.report("", emptyGVK, "type '%s' declares parameter '%s' several times", typeInfo.Type, parameter.Name)
+		}
+		names[parameter.Name] = true
+		if len(parameter.Default) > 0 {
+			if err := parameter.check(parameter.Default); err != nil {
+				c.report("", emptyGVK, "type '%s' declares an invalid default value: %v", typeInfo.Type, err)
+			}
+		}
+	}
+}
+
+func (c *conformanceChecker) isSupported(sample *halkyon.Capability) bool {
+	if !categoryKey(c.resource.GetSupportedCategory()).Equals(categoryKey(sample.Spec.Category)) {
+		return false
+	}
+	for _, typeInfo := range c.resource.GetSupportedTypes() {
+		if typeKey(typeInfo.Type).Equals(typeKey(sample.Spec.Type)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *conformanceChecker) checkSample(sample *halkyon.Capability) {
+	name := sample.Name
+	if !c.isSupported(sample) {
+		c.report(name, emptyGVK, "sample with category '%s' and type '%s' is not supported by the plugin", sample.Spec.Category, sample.Spec.Type)
+		return
+	}
+
+	c.safely(name, emptyGVK, "CheckValidity", func() { c.resource.CheckValidity(sample) })
+
+	var dependents, again []framework.DependentResource
+	if !c.safely(name, emptyGVK, "GetDependentResourcesWith", func() {
+		dependents = c.resource.GetDependentResourcesWith(sample)
+		again = c.resource.GetDependentResourcesWith(sample)
+	}) {
+		return
+	}
+	if len(dependents) == 0 {
+		c.report(name, emptyGVK, "GetDependentResourcesWith must return at least one dependent")
+		return
+	}
+
+	// dependents are identified by their GroupVersionKind so they must be unique and stable
+	names := make(map[schema.GroupVersionKind]string, len(dependents))
+	for _, dependent := range again {
+		names[dependent.GetConfig().GroupVersionKind] = dependent.Name()
+	}
+	seen := make(map[schema.GroupVersionKind]bool, len(dependents))
+	for _, dependent := range dependents {
+		gvk := dependent.GetConfig().GroupVersionKind
+		if gvk.Empty() {
+			c.report(name, gvk, "GetConfig must return a configuration with a non-empty GroupVersionKind")
+			continue
+		}
+		if seen[gvk] {
+			c.report(name, gvk, "several dependents share the same GroupVersionKind, only the first one can be reached by the host")
+			continue
+		}
+		seen[gvk] = true
+		if otherName, ok := names[gvk]; !ok || otherName != dependent.Name() {
+			c.report(name, gvk, "Name must be stable for a given owner, got '%s' then '%s'", dependent.Name(), otherName)
+		}
+		c.checkDependent(sample, dependent)
+	}
+}
+
+func (c *conformanceChecker) checkDependent(sample *halkyon.Capability, dependent framework.DependentResource) {
+	name := sample.Name
+	config := dependent.GetConfig()
+	gvk := config.GroupVersionKind
+	if len(config.TypeName) == 0 {
+		c.report(name, gvk, "GetConfig must return a configuration with a non-empty TypeName")
+	}
+	if c.scheme != nil && !c.scheme.Recognizes(gvk) {
+		c.report(name, gvk, "type is not registered in the host scheme")
+	}
+	if dependent.Owner() == nil {
+		c.report(name, gvk, "Owner must not be nil")
+	}
+	if len(dependent.Name()) == 0 {
+		c.report(name, gvk, "Name must not be empty")
+	}
+
+	// Build(true) is used to deserialize objects sent by the host: it needs to return an empty typed object
+	var empty runtime.Object
+	var err error
+	if c.safely(name, gvk, "Build(true)", func() { empty, err = dependent.Build(true) }) {
+		c.checkEmpty(name, gvk, empty, err)
+	}
+
+	// Build(false) is used to create the dependent on the cluster
+	var built runtime.Object
+	if !c.safely(name, gvk, "Build(false)", func() { built, err = dependent.Build(false) }) {
+		return
+	}
+	if err != nil {
+		c.report(name, gvk, "Build(false) returned an error: %v", err)
+		return
+	}
+	if built == nil {
+		c.report(name, gvk, "Build(false) must not return a nil object")
+		return
+	}
+	if _, err := framework.CreateUnstructuredObject(built, gvk); err != nil {
+		c.report(name, gvk, "built object cannot be serialized: %v", err)
+	}
+	if meta, ok := built.(metaObject); ok {
+		if meta.GetName() != dependent.Name() {
+			c.report(name, gvk, "built object is named '%s' but Name returns '%s'", meta.GetName(), dependent.Name())
+		}
+		if ns := meta.GetNamespace(); len(ns) > 0 && ns != sample.Namespace {
+			c.report(name, gvk, "built object is in namespace '%s' instead of its owner's '%s'", ns, sample.Namespace)
+		}
+	}
+
+	// the host dereferences the returned condition
+	c.safely(name, gvk, "GetCondition", func() {
+		if dependent.GetCondition(built.DeepCopyObject(), nil) == nil {
+			c.report(name, gvk, "GetCondition must not return a nil condition")
+		}
+	})
+
+	// the host serializes the updated object when the dependent is configured to be updated
+	if config.Updated {
+		c.safely(name, gvk, "Update", func() {
+			_, updated, err := dependent.Update(built.DeepCopyObject())
+			if err == nil && updated == nil {
+				c.report(name, gvk, "Update must return the object to update when the dependent is configured to be updated")
+			}
+		})
+	}
+}
+
+func (c *conformanceChecker) checkEmpty(name string, gvk schema.GroupVersionKind, empty runtime.Object, err error) {
+	switch {
+	case err != nil:
+		c.report(name, gvk, "Build(true) returned an error: %v", err)
+	case empty == nil:
+		c.report(name, gvk, "Build(true) must return an empty typed object, got nil")
+	case reflect.TypeOf(empty).Kind() != reflect.Ptr:
+		c.report(name, gvk, "Build(true) must return a pointer to an empty typed object, got %T", empty)
+	default:
+		if _, ok := empty.(*unstructured.Unstructured); ok {
+			c.report(name, gvk, "Build(true) must return a typed object, got Unstructured")
+			return
+		}
+		if c.scheme == nil {
+			return
+		}
+		if objectGVK, err := apiutil.GVKForObject(empty, c.scheme); err == nil && objectGVK != gvk {
+			c.report(name, gvk, "Build(true) returned a %v object", objectGVK)
+		}
+		if expected, err := c.scheme.New(gvk); err == nil {
+			actual := empty.DeepCopyObject()
+			actual.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+			if !reflect.DeepEqual(expected, actual) {
+				c.report(name, gvk, "Build(true) must return an empty object, got %v", empty)
+			}
+		}
+	}
+}
+
+type metaObject interface {
+	GetName() string
+	GetNamespace() string
+}
@@ -0,0 +1,353 @@
+package capability
+
+import (
+	"fmt"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"strings"
+	"testing"
+)
+
+var configMapGVK = corev1.SchemeGroupVersion.WithKind("ConfigMap")
+
+// conformanceDependent is a DependentResource conforming to the plugins contract unless its fields are changed
+type conformanceDependent struct {
+	name      string
+	owner     framework.SerializableResource
+	config    framework.DependentResourceConfig
+	build     func(empty bool) (runtime.Object, error)
+	condition *v1beta1.DependentCondition
+	update    func(toUpdate runtime.Object) (bool, runtime.Object, error)
+}
+
+func (d *conformanceDependent) Name() string {
+	return d.name
+}
+
+func (d *conformanceDependent) Owner() framework.SerializableResource {
+	return d.owner
+}
+
+func (d *conformanceDependent) Fetch() (runtime.Object, error) {
+	return nil, nil
+}
+
+func (d *conformanceDependent) Build(empty bool) (runtime.Object, error) {
+	if d.build != nil {
+		return d.build(empty)
+	}
+	if empty {
+		return &corev1.ConfigMap{}, nil
+	}
+	return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: d.owner.GetNamespace()}}, nil
+}
+
+func (d *conformanceDependent) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	if d.update != nil {
+		return d.update(toUpdate)
+	}
+	return false, toUpdate, nil
+}
+
+func (d *conformanceDependent) GetCondition(underlying runtime.Object, err error) *v1beta1.DependentCondition {
+	return d.condition
+}
+
+func (d *conformanceDependent) GetConfig() framework.DependentResourceConfig {
+	return d.config
+}
+
+// conformanceResource is a PluginResource conforming to the plugins contract unless its fields are changed
+type conformanceResource struct {
+	category   halkyon.CapabilityCategory
+	types      []TypeInfo
+	dependents func(owner framework.SerializableResource) []framework.DependentResource
+	validity   func(owner framework.SerializableResource) []string
+}
+
+func (r *conformanceResource) GetSupportedCategory() halkyon.CapabilityCategory {
+	return r.category
+}
+
+func (r *conformanceResource) GetSupportedTypes() []TypeInfo {
+	return r.types
+}
+
+func (r *conformanceResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
+	return r.dependents(owner)
+}
+
+func (r *conformanceResource) CheckValidity(owner framework.SerializableResource) []string {
+	if r.validity != nil {
+		return r.validity(owner)
+	}
+	return nil
+}
+
+func newConfigMapDependent(owner framework.SerializableResource) *conformanceDependent {
+	config := framework.NewConfig(configMapGVK)
+	return &conformanceDependent{name: owner.GetName() + "-config", owner: owner, config: config, condition: &v1beta1.DependentCondition{}}
+}
+
+// newConformanceResource creates a conforming conformanceResource which dependents are modified by the specified function, if any
+func newConformanceResource(modify func(dependent *conformanceDependent)) *conformanceResource {
+	return &conformanceResource{
+		category: "database",
+		types:    []TypeInfo{postgres},
+		dependents: func(owner framework.SerializableResource) []framework.DependentResource {
+			dependent := newConfigMapDependent(owner)
+			if modify != nil {
+				modify(dependent)
+			}
+			return []framework.DependentResource{dependent}
+		},
+	}
+}
+
+func TestCheckConformance(t *testing.T) {
+	sample := newCapability("db", "database", "postgres", "11")
+	secretTyped := func(empty bool) (runtime.Object, error) { return &corev1.Secret{}, nil }
+	tests := []struct {
+		name      string
+		resource  *conformanceResource
+		samples   []*halkyon.Capability
+		violation string
+	}{
+		{name: "conforming", resource: newConformanceResource(nil)},
+		{name: "empty category", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.category = ""
+			return r
+		}(), violation: "GetSupportedCategory must return a non-empty category"},
+		{name: "no types", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = nil
+			return r
+		}(), samples: []*halkyon.Capability{}, violation: "GetSupportedTypes must return at least one type"},
+		{name: "empty type", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{postgres, {}}
+			return r
+		}(), violation: "GetSupportedTypes must not return empty types"},
+		{name: "duplicated type", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{postgres, {Type: "Postgres"}}
+			return r
+		}(), violation: "GetSupportedTypes returned type 'Postgres' several times"},
+		{name: "invalid version", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{{Type: "postgres", Versions: []string{"11", ">=eleven"}}}
+			return r
+		}(), violation: "type 'postgres' declares an invalid version"},
+		{name: "empty version", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{{Type: "postgres", Versions: []string{"11", " "}}}
+			return r
+		}(), violation: "type 'postgres' declares an empty version"},
+		{name: "duplicated version", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{{Type: "postgres", Versions: []string{"11", "11"}}}
+			return r
+		}(), violation: "type 'postgres' declares version '11' several times"},
+		{name: "unnamed parameter", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{{Type: "postgres", Parameters: []ParameterSchema{{Type: StringParameter}}}}
+			return r
+		}(), violation: "type 'postgres' declares a parameter without a name"},
+		{name: "duplicated parameter", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{{Type: "postgres", Parameters: []ParameterSchema{{Name: "DB_NAME"}, {Name: "DB_NAME"}}}}
+			return r
+		}(), violation: "type 'postgres' declares parameter 'DB_NAME' several times"},
+		{name: "invalid default", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.types = []TypeInfo{{Type: "postgres", Parameters: []ParameterSchema{{Name: "DB_PORT", Type: IntegerParameter, Default: "port"}}}}
+			return r
+		}(), violation: "type 'postgres' declares an invalid default value"},
+		{name: "unsupported sample", resource: newConformanceResource(nil), samples: []*halkyon.Capability{newCapability("cache", "database", "redis", "5")},
+			violation: "'cache' capability: sample with category 'database' and type 'redis' is not supported by the plugin"},
+		{name: "panicking CheckValidity", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.validity = func(owner framework.SerializableResource) []string { panic("boom") }
+			return r
+		}(), violation: "'db' capability: CheckValidity panicked: boom"},
+		{name: "panicking GetDependentResourcesWith", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.dependents = func(owner framework.SerializableResource) []framework.DependentResource { panic("boom") }
+			return r
+		}(), violation: "GetDependentResourcesWith panicked: boom"},
+		{name: "no dependents", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.dependents = func(owner framework.SerializableResource) []framework.DependentResource { return nil }
+			return r
+		}(), violation: "GetDependentResourcesWith must return at least one dependent"},
+		{name: "empty GVK", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.config.GroupVersionKind = schema.GroupVersionKind{}
+		}), violation: "GetConfig must return a configuration with a non-empty GroupVersionKind"},
+		{name: "shared GVK", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			r.dependents = func(owner framework.SerializableResource) []framework.DependentResource {
+				other := newConfigMapDependent(owner)
+				other.name = "other"
+				return []framework.DependentResource{newConfigMapDependent(owner), other}
+			}
+			return r
+		}(), violation: "several dependents share the same GroupVersionKind"},
+		{name: "unstable name", resource: func() *conformanceResource {
+			r := newConformanceResource(nil)
+			calls := 0
+			r.dependents = func(owner framework.SerializableResource) []framework.DependentResource {
+				calls++
+				dependent := newConfigMapDependent(owner)
+				dependent.name = fmt.Sprintf("config-%d", calls)
+				return []framework.DependentResource{dependent}
+			}
+			return r
+		}(), violation: "Name must be stable for a given owner, got 'config-1' then 'config-2'"},
+		{name: "empty type name", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.config.TypeName = ""
+		}), violation: "GetConfig must return a configuration with a non-empty TypeName"},
+		{name: "unknown GVK", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.config.GroupVersionKind = schema.GroupVersionKind{Group: "kubedb.com", Version: "v1alpha1", Kind: "Postgres"}
+		}), violation: "type is not registered in the host scheme"},
+		{name: "nil owner", resource: newConformanceResource(func(d *conformanceDependent) {
+			namespace := d.owner.GetNamespace()
+			d.owner = nil
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return &corev1.ConfigMap{}, nil
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: namespace}}, nil
+			}
+		}), violation: "Owner must not be nil"},
+		{name: "empty name", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.name = ""
+		}), violation: "Name must not be empty"},
+		{name: "panicking Build(true)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					panic("boom")
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: "test"}}, nil
+			}
+		}), violation: "Build(true) panicked: boom"},
+		{name: "failing Build(true)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return nil, fmt.Errorf("boom")
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: "test"}}, nil
+			}
+		}), violation: "Build(true) returned an error: boom"},
+		{name: "nil Build(true)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return nil, nil
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: "test"}}, nil
+			}
+		}), violation: "Build(true) must return an empty typed object, got nil"},
+		{name: "unstructured Build(true)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return framework.CreateEmptyUnstructured(configMapGVK), nil
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: "test"}}, nil
+			}
+		}), violation: "Build(true) must return a typed object, got Unstructured"},
+		{name: "wrong type Build(true)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = secretTyped
+		}), violation: "Build(true) returned a /v1, Kind=Secret object"},
+		{name: "non-empty Build(true)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: "test"}}, nil
+			}
+		}), violation: "Build(true) must return an empty object"},
+		{name: "panicking Build(false)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return &corev1.ConfigMap{}, nil
+				}
+				panic("boom")
+			}
+		}), violation: "Build(false) panicked: boom"},
+		{name: "failing Build(false)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return &corev1.ConfigMap{}, nil
+				}
+				return nil, fmt.Errorf("boom")
+			}
+		}), violation: "Build(false) returned an error: boom"},
+		{name: "nil Build(false)", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return &corev1.ConfigMap{}, nil
+				}
+				return nil, nil
+			}
+		}), violation: "Build(false) must not return a nil object"},
+		{name: "misnamed object", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return &corev1.ConfigMap{}, nil
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "test"}}, nil
+			}
+		}), violation: "built object is named 'other' but Name returns 'db-config'"},
+		{name: "object in other namespace", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.build = func(empty bool) (runtime.Object, error) {
+				if empty {
+					return &corev1.ConfigMap{}, nil
+				}
+				return &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: d.name, Namespace: "other"}}, nil
+			}
+		}), violation: "built object is in namespace 'other' instead of its owner's 'test'"},
+		{name: "nil condition", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.condition = nil
+		}), violation: "GetCondition must not return a nil condition"},
+		{name: "updated without object", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.config.Updated = true
+			d.update = func(toUpdate runtime.Object) (bool, runtime.Object, error) { return true, nil, nil }
+		}), violation: "Update must return the object to update when the dependent is configured to be updated"},
+		{name: "panicking Update", resource: newConformanceResource(func(d *conformanceDependent) {
+			d.config.Updated = true
+			d.update = func(toUpdate runtime.Object) (bool, runtime.Object, error) { panic("boom") }
+		}), violation: "Update panicked: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := tt.samples
+			if samples == nil {
+				samples = []*halkyon.Capability{sample}
+			}
+			violations := CheckConformance(tt.resource, scheme.Scheme, samples...)
+			if len(tt.violation) == 0 {
+				for _, violation := range violations {
+					t.Errorf("unexpected violation: %v", violation)
+				}
+				return
+			}
+			for _, violation := range violations {
+				if strings.Contains(violation.Error(), tt.violation) {
+					return
+				}
+			}
+			t.Errorf("expected violation '%s', got %v", tt.violation, violations)
+		})
+	}
+}
+
+func TestConformanceViolationIdentifiesCapabilityAndDependent(t *testing.T) {
+	violation := ConformanceViolation{Capability: "db", Dependent: configMapGVK, Message: "Name must not be empty"}
+	expected := "'db' capability: /v1, Kind=ConfigMap dependent: Name must not be empty"
+	if violation.Error() != expected {
+		t.Errorf("expected '%s', got '%s'", expected, violation.Error())
+	}
+}
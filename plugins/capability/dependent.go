@@ -15,14 +15,16 @@ type PluginDependentResource struct {
 	gvk    schema.GroupVersionKind
 	owner  framework.SerializableResource
 	name   *string
+	helper *framework.K8SHelper
 }
 
-var _ framework.DependentResource = &PluginDependentResource{}
+var _ framework.ContextAwareDependentResource = &PluginDependentResource{}
+var _ framework.HelperAware = &PluginDependentResource{}
 
 func (p *PluginDependentResource) Name() string {
 	if p.name == nil {
 		name := ""
-		p.client.call("Name", p.gvk, &name)
+		p.client.call(p.owner, "Name", p.gvk, &name)
 		p.name = &name
 	}
 	return *p.name
@@ -33,40 +35,74 @@ func (p PluginDependentResource) Owner() framework.SerializableResource {
 }
 
 func (p PluginDependentResource) Fetch() (runtime.Object, error) {
+	return p.FetchWithContext(context.TODO())
+}
+
+func (p PluginDependentResource) FetchWithContext(ctx context.Context) (runtime.Object, error) {
 	into := framework.CreateEmptyUnstructured(p.GetConfig().GroupVersionKind)
-	if err := framework.Helper.Client.Get(context.TODO(), types.NamespacedName{Name: p.Name(), Namespace: p.owner.GetNamespace()}, into); err != nil {
+	if err := p.GetHelper().Client.Get(ctx, types.NamespacedName{Name: p.Name(), Namespace: p.owner.GetNamespace()}, into); err != nil {
 		return nil, err
 	}
 	return into, nil
 }
 
-func (p PluginDependentResource) Build(_ bool) (runtime.Object, error) {
+func (p PluginDependentResource) Build(empty bool) (runtime.Object, error) {
+	return p.BuildWithContext(context.Background(), empty)
+}
+
+func (p PluginDependentResource) BuildWithContext(ctx context.Context, _ bool) (runtime.Object, error) {
 	b := &BuildResponse{}
-	p.client.call("Build", p.gvk, b)
+	if err := p.client.callWithContext(ctx, p.owner, "Build", p.gvk, b); err != nil {
+		return nil, err
+	}
 	return b.Built, nil
 }
 
 func (p PluginDependentResource) Update(toUpdate runtime.Object) (bool, runtime.Object, error) {
+	return p.UpdateWithContext(context.Background(), toUpdate)
+}
+
+func (p PluginDependentResource) UpdateWithContext(ctx context.Context, toUpdate runtime.Object) (bool, runtime.Object, error) {
 	res := UpdateResponse{}
-	p.client.call("Update", p.gvk, &res, toUpdate)
+	if err := p.client.callWithContext(ctx, p.owner, "Update", p.gvk, &res, toUpdate); err != nil {
+		return false, toUpdate, err
+	}
 	return res.NeedsUpdate, res.Updated, res.Error
 }
 
-func (p *PluginDependentResource) GetCondition(underlying runtime.Object, err error) (res *v1beta1.DependentCondition) {
+func (p *PluginDependentResource) GetCondition(underlying runtime.Object, err error) *v1beta1.DependentCondition {
+	return p.GetConditionWithContext(context.Background(), underlying, err)
+}
+
+func (p *PluginDependentResource) GetConditionWithContext(ctx context.Context, underlying runtime.Object, err error) (res *v1beta1.DependentCondition) {
 	// we cannot serialize the error, so have to rely on default error handling
 	if c := framework.ErrorDependentCondition(p, err); c != nil {
 		return c
 	}
 	res = &v1beta1.DependentCondition{}
-	p.client.call("GetCondition", p.gvk, res, underlying)
+	if e := p.client.callWithContext(ctx, p.owner, "GetCondition", p.gvk, res, underlying); e != nil {
+		return framework.ErrorDependentCondition(p, e)
+	}
 	return
 }
 
 func (p *PluginDependentResource) GetConfig() framework.DependentResourceConfig {
 	if p.config == nil {
 		config := &framework.DependentResourceConfig{}
-		p.client.call("GetConfig", p.gvk, config)
+		p.client.call(p.owner, "GetConfig", p.gvk, config)
 		p.config = config
 	}
 	return *p.config
 }
+
+// GetHelper returns the K8SHelper this PluginDependentResource was given, if any, or the one its owner uses otherwise
+func (p *PluginDependentResource) GetHelper() *framework.K8SHelper {
+	if p.helper != nil {
+		return p.helper
+	}
+	return framework.HelperFor(p.owner)
+}
+
+func (p *PluginDependentResource) SetHelper(helper *framework.K8SHelper) {
+	p.helper = helper
+}
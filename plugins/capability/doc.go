@@ -1,5 +1,19 @@
 /*
 Package capability provides the infrastructure to expose Halkyon Capabilities as plugins. Plugins are created calling NewPlugin.
+
+Concurrency model
+
+Each plugin runs in its own process with which the host communicates over a single RPC connection, on which calls are
+multiplexed. Many Capabilities can therefore be reconciled in parallel (e.g. with MaxConcurrentReconciles > 1) through the same
+plugin process:
+
+  - on the host side, a PluginClient is safe for concurrent use: request-scoped state (owner, target dependent and argument) is
+    only carried by each PluginRequest, the plugin's category and types are cached lock-free and CapabilityInfo publication is
+    serialized by the Registry. DependentResources returned by ReadyFor are bound to the owner they were created for and are
+    meant to be used by the reconcile loop which created them,
+  - on the plugin side, calls are served concurrently, each in its own goroutine, so PluginResource implementations must not
+    keep per-request state across calls and need to synchronize any state they share between requests. The provided stems are
+    safe for concurrent use once initialized.
 */
 
 package capability
@@ -0,0 +1,42 @@
+package capability
+
+import (
+	"github.com/go-logr/logr"
+	"net"
+	"net/rpc"
+)
+
+// NewInProcessPlugin creates a Plugin backed by the specified PluginResources which are served in the host process instead of a
+// separate plugin process. Host and plugin still communicate using net/rpc, albeit over an in-memory connection, so that calls
+// go through the exact same serialization as with regular plugins. This is mostly useful to test PluginResource implementations
+// without having to build and launch a plugin binary. Note that the resulting Plugin is not registered.
+func NewInProcessPlugin(name string, log logr.Logger, config PluginConfig, resources ...PluginResource) (Plugin, error) {
+	logger := newLogrLogger(log, config.LogLevel).Named(name)
+	capability, err := NewAggregatePluginResource(logger, resources...)
+	if err != nil {
+		return nil, err
+	}
+
+	// serve the plugin
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", &PluginServerImpl{capability: capability, logger: logger}); err != nil {
+		return nil, err
+	}
+	hostConn, pluginConn := net.Pipe()
+	go server.ServeConn(pluginConn)
+
+	// serve the cluster access the plugin might need
+	kubeProxy := rpc.NewServer()
+	if err := kubeProxy.RegisterName("Plugin", newKubeProxyServer(name, config, log)); err != nil {
+		return nil, err
+	}
+	proxyHostConn, proxyPluginConn := net.Pipe()
+	go kubeProxy.ServeConn(proxyHostConn)
+	if needsClient, ok := capability.(NeedsKubeClient); ok {
+		needsClient.SetKubeClient(newKubeProxyClient(proxyPluginConn))
+	}
+
+	p := newPluginClient(name, rpc.NewClient(hostConn))
+	p.log = log
+	return p, nil
+}
@@ -0,0 +1,171 @@
+package capability
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	framework "halkyon.io/operator-framework"
+	"io"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"net/rpc"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// KubeClient provides plugins with a read-only, namespaced access to the cluster the host is running on. Calls are brokered by
+// the host which checks them against the GroupVersionKinds the plugin is allowed to access and audits them.
+type KubeClient interface {
+	// Get retrieves the object with the specified GroupVersionKind, namespace and name. As with the regular Kubernetes client,
+	// a NotFound error is returned if no such object exists.
+	Get(gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error)
+	// List retrieves the objects with the specified GroupVersionKind in the given namespace, optionally restricted to those
+	// matching the specified label selector (an empty selector matching all objects).
+	List(gvk schema.GroupVersionKind, namespace, labelSelector string) ([]unstructured.Unstructured, error)
+}
+
+// NeedsKubeClient is implemented by PluginResources that need to access the cluster. The host-provided KubeClient is passed to
+// these resources when the host connects to the plugin.
+type NeedsKubeClient interface {
+	SetKubeClient(client KubeClient)
+}
+
+// KubeProxyRequest is the request plugins send to the host when accessing the cluster
+type KubeProxyRequest struct {
+	GVK           schema.GroupVersionKind
+	Namespace     string
+	Name          string
+	LabelSelector string
+}
+
+// KubeProxyGetResponse is the response sent by the host when a plugin requests an object
+type KubeProxyGetResponse struct {
+	Object   *unstructured.Unstructured
+	NotFound bool
+}
+
+// KubeProxyListResponse is the response sent by the host when a plugin lists objects
+type KubeProxyListResponse struct {
+	Items []unstructured.Unstructured
+}
+
+// KubeProxyServer is the host-side implementation of the cluster access brokered to plugins. It only serves requests for
+// GroupVersionKinds explicitly allowed for the associated plugin and logs every call it receives.
+type KubeProxyServer struct {
+	plugin  string
+	allowed map[schema.GroupVersionKind]bool
+	helper  *framework.K8SHelper
+	log     logr.Logger
+}
+
+// newKubeProxyServer creates a KubeProxyServer for the specified plugin, accessing the cluster using the K8SHelper and restricted
+// to the GroupVersionKinds specified by the given PluginConfig
+func newKubeProxyServer(pluginName string, config PluginConfig, log logr.Logger) *KubeProxyServer {
+	allowed := config.AllowedGVKs
+	server := &KubeProxyServer{
+		plugin:  pluginName,
+		allowed: make(map[schema.GroupVersionKind]bool, len(allowed)),
+		helper:  config.helper(),
+		log:     log,
+	}
+	for _, gvk := range allowed {
+		server.allowed[gvk] = true
+	}
+	return server
+}
+
+func (k *KubeProxyServer) Get(req KubeProxyRequest, res *KubeProxyGetResponse) error {
+	if err := k.audit("Get", req); err != nil {
+		return err
+	}
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+	into := framework.CreateEmptyUnstructured(req.GVK)
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: req.Name, Namespace: req.Namespace}, into); err != nil {
+		if errors.IsNotFound(err) {
+			res.NotFound = true
+			return nil
+		}
+		return err
+	}
+	res.Object = into
+	return nil
+}
+
+func (k *KubeProxyServer) List(req KubeProxyRequest, res *KubeProxyListResponse) error {
+	if err := k.audit("List", req); err != nil {
+		return err
+	}
+	c, err := k.client()
+	if err != nil {
+		return err
+	}
+	selector, err := labels.Parse(req.LabelSelector)
+	if err != nil {
+		return err
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(req.GVK.GroupVersion().WithKind(req.GVK.Kind + "List"))
+	if err := c.List(context.TODO(), list, client.InNamespace(req.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	res.Items = list.Items
+	return nil
+}
+
+// client returns the Kubernetes client used to serve the plugin's requests or an error if the K8SHelper isn't initialized yet
+func (k *KubeProxyServer) client() (client.Client, error) {
+	if k.helper.Client == nil {
+		return nil, fmt.Errorf("cluster access is not available to '%s' plugin yet: Kubernetes client isn't initialized", k.plugin)
+	}
+	return k.helper.Client, nil
+}
+
+// audit logs the specified call and checks whether it is allowed
+func (k *KubeProxyServer) audit(method string, req KubeProxyRequest) error {
+	allowed := k.allowed[req.GVK]
+	k.log.Info("plugin cluster access", "plugin", k.plugin, "method", method, "gvk", req.GVK.String(),
+		"namespace", req.Namespace, "name", req.Name, "labelSelector", req.LabelSelector, "allowed", allowed)
+	if !allowed {
+		return fmt.Errorf("'%s' plugin is not allowed to access %v", k.plugin, req.GVK)
+	}
+	if len(req.Namespace) == 0 {
+		return fmt.Errorf("'%s' plugin must specify a namespace to access %v", k.plugin, req.GVK)
+	}
+	return nil
+}
+
+// kubeProxyClient is the plugin-side KubeClient implementation, forwarding calls to the host's KubeProxyServer
+type kubeProxyClient struct {
+	client *rpc.Client
+}
+
+var _ KubeClient = &kubeProxyClient{}
+
+func newKubeProxyClient(conn io.ReadWriteCloser) *kubeProxyClient {
+	return &kubeProxyClient{client: rpc.NewClient(conn)}
+}
+
+func (k *kubeProxyClient) Get(gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	res := KubeProxyGetResponse{}
+	if err := k.client.Call("Plugin.Get", KubeProxyRequest{GVK: gvk, Namespace: namespace, Name: name}, &res); err != nil {
+		return nil, err
+	}
+	if res.NotFound {
+		return nil, errors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind)}, name)
+	}
+	return res.Object, nil
+}
+
+func (k *kubeProxyClient) List(gvk schema.GroupVersionKind, namespace, labelSelector string) ([]unstructured.Unstructured, error) {
+	res := KubeProxyListResponse{}
+	if err := k.client.Call("Plugin.List", KubeProxyRequest{GVK: gvk, Namespace: namespace, LabelSelector: labelSelector}, &res); err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
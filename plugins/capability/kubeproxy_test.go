@@ -0,0 +1,263 @@
+package capability
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	framework "halkyon.io/operator-framework"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"net"
+	"net/rpc"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logEntry is an entry recorded by a recordingLogger
+type logEntry struct {
+	level         int
+	name          string
+	msg           string
+	err           error
+	keysAndValues []interface{}
+}
+
+// value returns the value associated with the specified key in this logEntry, nil if there's none
+func (e logEntry) value(key string) interface{} {
+	for i := 0; i+1 < len(e.keysAndValues); i += 2 {
+		if e.keysAndValues[i] == key {
+			return e.keysAndValues[i+1]
+		}
+	}
+	return nil
+}
+
+// recordedEntries gathers the entries recorded by a recordingLogger and the loggers derived from it
+type recordedEntries struct {
+	mutex   sync.Mutex
+	entries []logEntry
+}
+
+func (r *recordedEntries) all() []logEntry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]logEntry(nil), r.entries...)
+}
+
+// recordingLogger is a logr.Logger recording the entries it logs
+type recordingLogger struct {
+	recorded      *recordedEntries
+	level         int
+	name          string
+	keysAndValues []interface{}
+}
+
+var _ logr.Logger = recordingLogger{}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{recorded: &recordedEntries{}}
+}
+
+func (l recordingLogger) record(err error, msg string, keysAndValues []interface{}) {
+	l.recorded.mutex.Lock()
+	defer l.recorded.mutex.Unlock()
+	all := append(append([]interface{}(nil), l.keysAndValues...), keysAndValues...)
+	l.recorded.entries = append(l.recorded.entries, logEntry{level: l.level, name: l.name, msg: msg, err: err, keysAndValues: all})
+}
+
+func (l recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record(nil, msg, keysAndValues)
+}
+
+func (l recordingLogger) Enabled() bool {
+	return true
+}
+
+func (l recordingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.record(err, msg, keysAndValues)
+}
+
+func (l recordingLogger) V(level int) logr.InfoLogger {
+	l.level = level
+	return l
+}
+
+func (l recordingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	l.keysAndValues = append(append([]interface{}(nil), l.keysAndValues...), keysAndValues...)
+	return l
+}
+
+func (l recordingLogger) WithName(name string) logr.Logger {
+	if len(l.name) > 0 {
+		name = l.name + "." + name
+	}
+	l.name = name
+	return l
+}
+
+var secretGVK = corev1.SchemeGroupVersion.WithKind("Secret")
+
+func newKubeProxy(t *testing.T, logger logr.Logger) KubeClient {
+	secretType := v1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	secret := &corev1.Secret{TypeMeta: secretType, ObjectMeta: v1.ObjectMeta{Name: "credentials", Namespace: "test", Labels: map[string]string{"app": "db"}}}
+	other := &corev1.Secret{TypeMeta: secretType, ObjectMeta: v1.ObjectMeta{Name: "other", Namespace: "test"}}
+	configMap := &corev1.ConfigMap{TypeMeta: v1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}, ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "test"}}
+	fakeClient := fake.NewFakeClientWithScheme(scheme.Scheme, secret, other, configMap)
+	helper := &framework.K8SHelper{Client: unstructuredListClient{Client: fakeClient}, Scheme: scheme.Scheme}
+	return serveKubeProxy(t, newKubeProxyServer("db-plugin", PluginConfig{AllowedGVKs: []schema.GroupVersionKind{secretGVK}, Helper: helper}, logger))
+}
+
+// unstructuredListClient works around the fake client's inability to list objects into UnstructuredLists by listing typed
+// objects and converting them
+type unstructuredListClient struct {
+	client.Client
+}
+
+func (c unstructuredListClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	u, ok := list.(*unstructured.UnstructuredList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	typed, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	if err := c.Client.List(ctx, typed, opts...); err != nil {
+		return err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed)
+	if err != nil {
+		return err
+	}
+	u.SetUnstructuredContent(content)
+	return nil
+}
+
+// serveKubeProxy serves the specified KubeProxyServer over an in-memory connection, returning the plugin-side KubeClient
+func serveKubeProxy(t *testing.T, server *KubeProxyServer) KubeClient {
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("Plugin", server); err != nil {
+		t.Fatal(err)
+	}
+	hostConn, pluginConn := net.Pipe()
+	go rpcServer.ServeConn(hostConn)
+	return newKubeProxyClient(pluginConn)
+}
+
+func TestKubeProxyServesAllowedTypes(t *testing.T) {
+	c := newKubeProxy(t, newRecordingLogger())
+
+	secret, err := c.Get(secretGVK, "test", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.GetName() != "credentials" || secret.GetKind() != "Secret" {
+		t.Errorf("expected 'credentials' secret, got %v", secret)
+	}
+	if _, err := c.Get(secretGVK, "test", "missing"); !errors.IsNotFound(err) {
+		t.Errorf("expected NotFound error, got %v", err)
+	}
+
+	secrets, err := c.List(secretGVK, "test", "app=db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].GetName() != "credentials" {
+		t.Errorf("expected only 'credentials' secret to match, got %v", secrets)
+	}
+	if secrets, err = c.List(secretGVK, "test", ""); err != nil || len(secrets) != 2 {
+		t.Errorf("expected 2 secrets, got %v (error: %v)", secrets, err)
+	}
+}
+
+func TestKubeProxyDeniesAndAuditsAccess(t *testing.T) {
+	logger := newRecordingLogger()
+	c := newKubeProxy(t, logger)
+	configMapGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+
+	tests := []struct {
+		name  string
+		call  func() error
+		error string
+	}{
+		{
+			name: "get not allowed type",
+			call: func() error {
+				_, err := c.Get(configMapGVK, "test", "config")
+				return err
+			},
+			error: "'db-plugin' plugin is not allowed to access /v1, Kind=ConfigMap",
+		},
+		{
+			name: "list not allowed type",
+			call: func() error {
+				_, err := c.List(configMapGVK, "test", "")
+				return err
+			},
+			error: "'db-plugin' plugin is not allowed to access /v1, Kind=ConfigMap",
+		},
+		{
+			name: "get without namespace",
+			call: func() error {
+				_, err := c.Get(secretGVK, "", "credentials")
+				return err
+			},
+			error: "'db-plugin' plugin must specify a namespace",
+		},
+		{
+			name: "list without namespace",
+			call: func() error {
+				_, err := c.List(secretGVK, "", "")
+				return err
+			},
+			error: "'db-plugin' plugin must specify a namespace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error '%s', got %v", tt.error, err)
+			}
+		})
+	}
+
+	if _, err := c.Get(secretGVK, "test", "credentials"); err != nil {
+		t.Fatal(err)
+	}
+	entries := logger.recorded.all()
+	if len(entries) != len(tests)+1 {
+		t.Fatalf("expected %d audit entries, got %v", len(tests)+1, entries)
+	}
+	for i, expected := range []struct {
+		method  string
+		gvk     string
+		allowed bool
+	}{
+		{"Get", configMapGVK.String(), false},
+		{"List", configMapGVK.String(), false},
+		{"Get", secretGVK.String(), true},
+		{"List", secretGVK.String(), true},
+		{"Get", secretGVK.String(), true},
+	} {
+		entry := entries[i]
+		actual := fmt.Sprintf("%v %v %v %v", entry.value("plugin"), entry.value("method"), entry.value("gvk"), entry.value("allowed"))
+		if want := fmt.Sprintf("db-plugin %s %s %v", expected.method, expected.gvk, expected.allowed); actual != want {
+			t.Errorf("expected audit entry #%d to be '%s', got '%s'", i, want, actual)
+		}
+	}
+}
+
+func TestKubeProxyWithoutClient(t *testing.T) {
+	c := serveKubeProxy(t, newKubeProxyServer("db-plugin", PluginConfig{AllowedGVKs: []schema.GroupVersionKind{secretGVK}, Helper: &framework.K8SHelper{}}, newRecordingLogger()))
+	if _, err := c.Get(secretGVK, "test", "credentials"); err == nil || !strings.Contains(err.Error(), "isn't initialized") {
+		t.Errorf("expected an error reporting that the client isn't initialized, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package capability
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/go-hclog"
+	framework "halkyon.io/operator-framework"
+	"log"
+	"os"
+	"strings"
+)
+
+// PluginLogLevelEnvVar is the environment variable the host uses to tell plugins which log level they should use
+const PluginLogLevelEnvVar = "HALKYON_PLUGIN_LOG_LEVEL"
+
+// defaultLogLevel is the level used when no level has been configured
+const defaultLogLevel = hclog.Info
+
+var levelNames = map[hclog.Level]string{
+	hclog.Trace: "trace",
+	hclog.Debug: "debug",
+	hclog.Info:  "info",
+	hclog.Warn:  "warn",
+	hclog.Error: "error",
+}
+
+// levelOrDefault returns the specified level or the default one if no level was specified
+func levelOrDefault(level hclog.Level) hclog.Level {
+	if level == hclog.NoLevel {
+		return defaultLogLevel
+	}
+	return level
+}
+
+// newPluginLogger creates the hclog.Logger plugins use. Plugins log in JSON to their standard error so that the host can parse
+// their output and re-emit it via its own logger, at the level requested by the host.
+func newPluginLogger(name string) hclog.Logger {
+	return hclog.New(&hclog.LoggerOptions{
+		Output:     hclog.DefaultOutput,
+		Level:      levelOrDefault(hclog.LevelFromString(os.Getenv(PluginLogLevelEnvVar))),
+		Name:       name,
+		JSONFormat: true,
+	})
+}
+
+// requestLogger returns a logger decorated with the method being called and the name and namespace of the Capability it's
+// called for so that these are available as structured keys on the host
+func requestLogger(logger hclog.Logger, method string, req PluginRequest) hclog.Logger {
+	args := []interface{}{"method", method}
+	if req.Owner != nil {
+		args = append(args, "capability", req.Owner.GetName(), "namespace", req.Owner.GetNamespace())
+	}
+	return logger.With(args...)
+}
+
+// LoggerFor returns a logger decorated with the name and namespace of the specified owner so that logs emitted in the context of
+// a given Capability can be filtered on the host
+func LoggerFor(logger hclog.Logger, owner framework.SerializableResource) hclog.Logger {
+	return logger.With("capability", owner.GetName(), "namespace", owner.GetNamespace())
+}
+
+// logrLogger is an hclog.Logger implementation re-emitting logs via a logr.Logger. It is used by the host to process the logs
+// plugins output so that they are formatted consistently with the operator's logs.
+type logrLogger struct {
+	delegate logr.Logger
+	name     string
+	level    *hclog.Level
+	args     []interface{}
+}
+
+var _ hclog.Logger = &logrLogger{}
+
+// newLogrLogger creates a new hclog.Logger re-emitting logs at or above the specified level via the given logr.Logger
+func newLogrLogger(delegate logr.Logger, level hclog.Level) hclog.Logger {
+	level = levelOrDefault(level)
+	return &logrLogger{delegate: delegate, level: &level}
+}
+
+func (l *logrLogger) Trace(msg string, args ...interface{}) {
+	if l.IsTrace() {
+		l.delegate.V(2).Info(msg, l.keysAndValues(args)...)
+	}
+}
+
+func (l *logrLogger) Debug(msg string, args ...interface{}) {
+	if l.IsDebug() {
+		l.delegate.V(1).Info(msg, l.keysAndValues(args)...)
+	}
+}
+
+func (l *logrLogger) Info(msg string, args ...interface{}) {
+	if l.IsInfo() {
+		l.delegate.Info(msg, l.keysAndValues(args)...)
+	}
+}
+
+func (l *logrLogger) Warn(msg string, args ...interface{}) {
+	if l.IsWarn() {
+		l.delegate.Info(msg, append(l.keysAndValues(args), "level", levelNames[hclog.Warn])...)
+	}
+}
+
+func (l *logrLogger) Error(msg string, args ...interface{}) {
+	if l.IsError() {
+		keysAndValues := l.keysAndValues(args)
+		var err error
+		for i := 0; i < len(keysAndValues)-1; i += 2 {
+			if keysAndValues[i] == "error" {
+				err = fmt.Errorf("%v", keysAndValues[i+1])
+				break
+			}
+		}
+		l.delegate.Error(err, msg, keysAndValues...)
+	}
+}
+
+func (l *logrLogger) IsTrace() bool {
+	return *l.level <= hclog.Trace
+}
+
+func (l *logrLogger) IsDebug() bool {
+	return *l.level <= hclog.Debug
+}
+
+func (l *logrLogger) IsInfo() bool {
+	return *l.level <= hclog.Info
+}
+
+func (l *logrLogger) IsWarn() bool {
+	return *l.level <= hclog.Warn
+}
+
+func (l *logrLogger) IsError() bool {
+	return *l.level <= hclog.Error
+}
+
+func (l *logrLogger) With(args ...interface{}) hclog.Logger {
+	with := *l
+	with.args = append(append(make([]interface{}, 0, len(l.args)+len(args)), l.args...), args...)
+	return &with
+}
+
+func (l *logrLogger) Named(name string) hclog.Logger {
+	if len(l.name) > 0 {
+		name = l.name + "." + name
+	}
+	return l.ResetNamed(name)
+}
+
+func (l *logrLogger) ResetNamed(name string) hclog.Logger {
+	named := *l
+	named.name = name
+	return &named
+}
+
+// SetLevel updates the level of this logger and all the loggers derived from it
+func (l *logrLogger) SetLevel(level hclog.Level) {
+	*l.level = levelOrDefault(level)
+}
+
+func (l *logrLogger) StandardLogger(_ *hclog.StandardLoggerOptions) *log.Logger {
+	return log.New(&logrWriter{logger: l}, "", 0)
+}
+
+// keysAndValues converts the specified hclog arguments to logr keys and values, adding the plugin name and removing the
+// timestamp go-plugin adds since logr records its own
+func (l *logrLogger) keysAndValues(args []interface{}) []interface{} {
+	all := append(append(make([]interface{}, 0, len(l.args)+len(args)), l.args...), args...)
+	keysAndValues := make([]interface{}, 0, len(all)+2)
+	if len(l.name) > 0 {
+		keysAndValues = append(keysAndValues, "plugin", l.name)
+	}
+	for i := 0; i < len(all)-1; i += 2 {
+		if all[i] == "timestamp" {
+			continue
+		}
+		keysAndValues = append(keysAndValues, fmt.Sprintf("%v", all[i]), all[i+1])
+	}
+	return keysAndValues
+}
+
+// logrWriter adapts a logrLogger to the io.Writer interface so that it can be used as a standard library logger
+type logrWriter struct {
+	logger *logrLogger
+}
+
+func (w *logrWriter) Write(p []byte) (int, error) {
+	w.logger.Info(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
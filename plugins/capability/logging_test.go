@@ -0,0 +1,165 @@
+package capability
+
+import (
+	"fmt"
+	"github.com/hashicorp/go-hclog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogrLoggerLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   hclog.Level
+		emitted []string
+	}{
+		{name: "trace", level: hclog.Trace, emitted: []string{"trace", "debug", "info", "warn", "error"}},
+		{name: "debug", level: hclog.Debug, emitted: []string{"debug", "info", "warn", "error"}},
+		{name: "default", level: hclog.NoLevel, emitted: []string{"info", "warn", "error"}},
+		{name: "info", level: hclog.Info, emitted: []string{"info", "warn", "error"}},
+		{name: "warn", level: hclog.Warn, emitted: []string{"warn", "error"}},
+		{name: "error", level: hclog.Error, emitted: []string{"error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := newRecordingLogger()
+			l := newLogrLogger(logger, tt.level)
+			l.Trace("trace")
+			l.Debug("debug")
+			l.Info("info")
+			l.Warn("warn")
+			l.Error("error")
+
+			emitted := []string{}
+			for _, entry := range logger.recorded.all() {
+				emitted = append(emitted, entry.msg)
+			}
+			if !reflect.DeepEqual(emitted, tt.emitted) {
+				t.Errorf("expected %v to be emitted, got %v", tt.emitted, emitted)
+			}
+		})
+	}
+}
+
+func TestLogrLoggerMapsLevels(t *testing.T) {
+	logger := newRecordingLogger()
+	l := newLogrLogger(logger, hclog.Trace)
+	l.Trace("trace")
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("failed", "error", "connection refused", "attempt", 2)
+
+	entries := logger.recorded.all()
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(entries))
+	}
+	for i, level := range []int{2, 1, 0, 0} {
+		if entries[i].level != level || entries[i].err != nil {
+			t.Errorf("expected '%s' to be emitted at V(%d) without error, got V(%d) (%v)", entries[i].msg, level, entries[i].level, entries[i].err)
+		}
+	}
+	if level := entries[3].value("level"); level != "warn" {
+		t.Errorf("expected warning to be flagged with its level, got %v", level)
+	}
+	if err := entries[4].err; err == nil || err.Error() != "connection refused" {
+		t.Errorf("expected error to be extracted from the 'error' argument, got %v", err)
+	}
+	if attempt := entries[4].value("attempt"); attempt != 2 {
+		t.Errorf("expected error arguments to be kept, got %v", entries[4].keysAndValues)
+	}
+
+	// levels set on a logger apply to the loggers derived from it
+	named := l.Named("postgres")
+	l.SetLevel(hclog.Error)
+	if named.IsWarn() || !named.IsError() {
+		t.Error("expected level change to apply to derived loggers")
+	}
+}
+
+func TestLogrLoggerPropagatesNamesAndArguments(t *testing.T) {
+	logger := newRecordingLogger()
+	l := newLogrLogger(logger, hclog.Info)
+	named := l.Named("postgres").Named("client").With("capability", "db")
+	with := named.With("namespace", "test")
+	with.Info("connected", "timestamp", "2020-01-01T00:00:00Z", "port", 5432)
+	named.Info("parent")
+	l.ResetNamed("redis").Info("reset")
+	l.StandardLogger(nil).Println("standard")
+
+	entries := logger.recorded.all()
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	expected := []interface{}{"plugin", "postgres.client", "capability", "db", "namespace", "test", "port", 5432}
+	if !reflect.DeepEqual(entries[0].keysAndValues, expected) {
+		t.Errorf("expected name and arguments to be propagated without timestamp as %v, got %v", expected, entries[0].keysAndValues)
+	}
+	if expected := []interface{}{"plugin", "postgres.client", "capability", "db"}; !reflect.DeepEqual(entries[1].keysAndValues, expected) {
+		t.Errorf("expected derived logger's arguments not to leak into its parent, got %v", entries[1].keysAndValues)
+	}
+	if name := entries[2].value("plugin"); name != "redis" {
+		t.Errorf("expected name to be reset, got %v", name)
+	}
+	if entries[3].msg != "standard" || entries[3].value("plugin") != nil {
+		t.Errorf("expected standard logger to log trimmed message, got '%s' %v", entries[3].msg, entries[3].keysAndValues)
+	}
+}
+
+func TestPluginLogLevelHandoff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the fake plugin records the level it's given and exits without completing the handshake
+	recorded := filepath.Join(dir, "level")
+	path := filepath.Join(dir, "plugin")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$%s\" > %s\n", PluginLogLevelEnvVar, recorded)
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for level, expected := range map[hclog.Level]string{hclog.Debug: "debug", hclog.NoLevel: "info", hclog.Error: "error"} {
+		if _, err := startPlugin(path, newRecordingLogger(), PluginConfig{LogLevel: level}); err == nil {
+			t.Fatal("expected plugin start to fail")
+		}
+		handedOff, err := ioutil.ReadFile(recorded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := strings.TrimSpace(string(handedOff)); actual != expected {
+			t.Errorf("expected plugin to be started with level '%s', got '%s'", expected, actual)
+		}
+	}
+
+	// the configured level takes precedence over the one set in the host's environment
+	defer os.Unsetenv(PluginLogLevelEnvVar)
+	if err := os.Setenv(PluginLogLevelEnvVar, "error"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := startPlugin(path, newRecordingLogger(), PluginConfig{LogLevel: hclog.Debug}); err == nil {
+		t.Fatal("expected plugin start to fail")
+	}
+	handedOff, err := ioutil.ReadFile(recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := strings.TrimSpace(string(handedOff)); actual != "debug" {
+		t.Errorf("expected plugin to be started with configured level 'debug' rather than the host's one, got '%s'", actual)
+	}
+
+	// plugins use the level they're given
+	for value, debug := range map[string]bool{"debug": true, "warn": false, "": false} {
+		if err := os.Setenv(PluginLogLevelEnvVar, value); err != nil {
+			t.Fatal(err)
+		}
+		if l := newPluginLogger("postgres"); l.IsDebug() != debug || !l.IsWarn() {
+			t.Errorf("expected plugin logger using level '%s' to log debug messages: %v, got %v", value, debug, l.IsDebug())
+		}
+	}
+}
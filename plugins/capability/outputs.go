@@ -0,0 +1,39 @@
+package capability
+
+import (
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+)
+
+// Well-known output names that plugins are encouraged to use so that Components binding to capabilities can discover connection
+// information without relying on plugin-specific naming conventions
+const (
+	HostOutput       = "host"
+	PortOutput       = "port"
+	SecretNameOutput = "secretName"
+	DatabaseOutput   = "database"
+)
+
+// OutputsProvider is an optional interface PluginResources can implement to contribute capability-level outputs (e.g.
+// connection endpoint, secret name, port) to the Capabilities they handle
+type OutputsProvider interface {
+	// GetOutputs returns the outputs of the specified owner, as name / value pairs
+	GetOutputs(owner framework.SerializableResource) []v1beta1.NameValuePair
+}
+
+// OutputsFor retrieves the outputs of the specified Capability from the Plugin of this Registry handling it
+func (r *Registry) OutputsFor(owner *halkyon.Capability) ([]v1beta1.NameValuePair, error) {
+	p, err := r.GetPluginForCapability(owner)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetOutputs(owner)
+}
+
+// OutputsFor retrieves the outputs of the specified Capability from the Plugin of the DefaultRegistry handling it. Resources
+// representing Capabilities on the operator side implement framework.OutputsPublisher by forwarding GetOutputs to it so that the
+// outputs get recorded in the status of the Capabilities when they are reconciled.
+func OutputsFor(owner *halkyon.Capability) ([]v1beta1.NameValuePair, error) {
+	return DefaultRegistry.OutputsFor(owner)
+}
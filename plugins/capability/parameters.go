@@ -0,0 +1,151 @@
+package capability
+
+import (
+	"encoding/json"
+	"fmt"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	"halkyon.io/operator-framework/util"
+	"k8s.io/apimachinery/pkg/util/errors"
+	"strconv"
+	"strings"
+)
+
+// ParametersAnnotation is the annotation on CapabilityInfos recording, as JSON, the parameters supported by the registered
+// plugins so that tooling can render them. Its value is a list of ParametersInfo, one per registered plugin.
+const ParametersAnnotation = "halkyon.io/parameters"
+
+// ParameterType is the type of the value expected for a capability parameter
+type ParameterType string
+
+const (
+	StringParameter  ParameterType = "string"
+	IntegerParameter ParameterType = "integer"
+	NumberParameter  ParameterType = "number"
+	BooleanParameter ParameterType = "boolean"
+)
+
+// ParameterSchema describes a parameter a plugin accepts for a given CapabilityType
+type ParameterSchema struct {
+	// Name is the name of the parameter, as specified in the Capability's parameters
+	Name string `json:"name"`
+	// Type is the type of the parameter's value, defaults to StringParameter
+	Type ParameterType `json:"type,omitempty"`
+	// Required specifies whether the parameter needs to be provided, which only makes sense if it has no Default
+	Required bool `json:"required,omitempty"`
+	// Default is the value the parameter takes when it's not provided, if any
+	Default string `json:"default,omitempty"`
+	// Enum lists the values the parameter can take, any value being accepted if empty
+	Enum []string `json:"enum,omitempty"`
+	// Description describes the parameter for users
+	Description string `json:"description,omitempty"`
+}
+
+// ParametersInfo records the parameters a plugin supports for the specified versions of a CapabilityType, as published in the
+// ParametersAnnotation
+type ParametersInfo struct {
+	Plugin     string            `json:"plugin"`
+	Versions   []string          `json:"versions,omitempty"`
+	Parameters []ParameterSchema `json:"parameters"`
+}
+
+func (p ParameterSchema) check(value string) error {
+	var err error
+	switch p.Type {
+	case "", StringParameter:
+	case IntegerParameter:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case NumberParameter:
+		_, err = strconv.ParseFloat(value, 64)
+	case BooleanParameter:
+		_, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("parameter '%s' has unknown type '%s'", p.Name, p.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("parameter '%s' must be of type '%s', got '%s'", p.Name, p.Type, value)
+	}
+	if len(p.Enum) > 0 && util.Index(p.Enum, value) < 0 {
+		return fmt.Errorf("parameter '%s' must be one of %s, got '%s'", p.Name, strings.Join(p.Enum, ", "), value)
+	}
+	return nil
+}
+
+// ValidateParameters checks the specified parameters against the parameter schema of this TypeInfo, if any. Parameters not
+// declared in the schema are rejected.
+func (t TypeInfo) ValidateParameters(parameters []v1beta1.NameValuePair) error {
+	if len(t.Parameters) == 0 {
+		return nil
+	}
+	values := util.ParametersAsMap(parameters)
+	errs := make([]error, 0, len(values))
+	for _, schema := range t.Parameters {
+		value, ok := values[schema.Name]
+		if !ok {
+			if schema.Required && len(schema.Default) == 0 {
+				errs = append(errs, fmt.Errorf("missing required parameter '%s'", schema.Name))
+			}
+			continue
+		}
+		delete(values, schema.Name)
+		if err := schema.check(value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for name := range values {
+		errs = append(errs, fmt.Errorf("unknown parameter '%s' for type '%s'", name, t.Type))
+	}
+	return errors.NewAggregate(errs)
+}
+
+// WithDefaults returns the specified parameters, completed with the default values of the parameters declared in the schema of
+// this TypeInfo which are not specified. The specified parameters are not modified.
+func (t TypeInfo) WithDefaults(parameters []v1beta1.NameValuePair) []v1beta1.NameValuePair {
+	values := util.ParametersAsMap(parameters)
+	result := parameters
+	for _, schema := range t.Parameters {
+		if _, ok := values[schema.Name]; !ok && len(schema.Default) > 0 {
+			if len(result) == len(parameters) {
+				result = append(make([]v1beta1.NameValuePair, 0, len(parameters)+len(t.Parameters)), parameters...)
+			}
+			result = append(result, v1beta1.NameValuePair{Name: schema.Name, Value: schema.Default})
+		}
+	}
+	return result
+}
+
+// withDefaults returns the specified Capability with its parameters completed with the defaults declared by the specified
+// Plugin. The Capability is copied if needed so that the original one is never modified.
+func withDefaults(p Plugin, capability *halkyon.Capability) *halkyon.Capability {
+	if capability == nil {
+		return nil
+	}
+	typeInfo, ok := typeInfoFor(p, capability.Spec.Type)
+	if !ok {
+		return capability
+	}
+	parameters := typeInfo.WithDefaults(capability.Spec.Parameters)
+	if len(parameters) == len(capability.Spec.Parameters) {
+		return capability
+	}
+	defaulted := capability.DeepCopy()
+	defaulted.Spec.Parameters = parameters
+	return defaulted
+}
+
+// parametersAnnotation computes the value of the ParametersAnnotation for the specified plugins and CapabilityType
+func parametersAnnotation(capType halkyon.CapabilityType, plugins ...Plugin) (string, error) {
+	infos := make([]ParametersInfo, 0, len(plugins))
+	for _, p := range plugins {
+		typeInfo, _ := typeInfoFor(p, capType)
+		if len(typeInfo.Parameters) == 0 {
+			continue
+		}
+		infos = append(infos, ParametersInfo{Plugin: p.Name(), Versions: typeInfo.Versions, Parameters: typeInfo.Parameters})
+	}
+	if len(infos) == 0 {
+		return "", nil
+	}
+	bytes, err := json.Marshal(infos)
+	return string(bytes), err
+}
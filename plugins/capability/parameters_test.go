@@ -0,0 +1,155 @@
+package capability
+
+import (
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"strings"
+	"testing"
+)
+
+var postgres = TypeInfo{
+	Type:     "postgres",
+	Versions: []string{"10", "11"},
+	Parameters: []ParameterSchema{
+		{Name: "DB_NAME", Required: true},
+		{Name: "DB_PORT", Type: IntegerParameter, Default: "5432"},
+		{Name: "DB_RATIO", Type: NumberParameter},
+		{Name: "DB_SSL", Type: BooleanParameter, Default: "true"},
+		{Name: "DB_MODE", Enum: []string{"single", "cluster"}},
+	},
+}
+
+func parameters(nameValues ...string) []v1beta1.NameValuePair {
+	pairs := make([]v1beta1.NameValuePair, 0, len(nameValues)/2)
+	for i := 0; i < len(nameValues); i += 2 {
+		pairs = append(pairs, v1beta1.NameValuePair{Name: nameValues[i], Value: nameValues[i+1]})
+	}
+	return pairs
+}
+
+func TestValidateParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		typeInfo   TypeInfo
+		parameters []v1beta1.NameValuePair
+		errors     []string
+	}{
+		{name: "no schema", typeInfo: TypeInfo{Type: "postgres"}, parameters: parameters("ANYTHING", "goes")},
+		{name: "valid", typeInfo: postgres, parameters: parameters("DB_NAME", "db", "DB_PORT", "5433", "DB_RATIO", "0.5", "DB_SSL", "false", "DB_MODE", "cluster")},
+		{name: "defaults only", typeInfo: postgres, parameters: parameters("DB_NAME", "db")},
+		{name: "missing required", typeInfo: postgres, parameters: parameters("DB_PORT", "5433"), errors: []string{"missing required parameter 'DB_NAME'"}},
+		{name: "required with default", typeInfo: TypeInfo{Type: "postgres", Parameters: []ParameterSchema{{Name: "DB_NAME", Required: true, Default: "db"}}}},
+		{name: "invalid integer", typeInfo: postgres, parameters: parameters("DB_NAME", "db", "DB_PORT", "port"), errors: []string{"parameter 'DB_PORT' must be of type 'integer', got 'port'"}},
+		{name: "invalid number", typeInfo: postgres, parameters: parameters("DB_NAME", "db", "DB_RATIO", "half"), errors: []string{"parameter 'DB_RATIO' must be of type 'number', got 'half'"}},
+		{name: "invalid boolean", typeInfo: postgres, parameters: parameters("DB_NAME", "db", "DB_SSL", "maybe"), errors: []string{"parameter 'DB_SSL' must be of type 'boolean', got 'maybe'"}},
+		{name: "not in enum", typeInfo: postgres, parameters: parameters("DB_NAME", "db", "DB_MODE", "sharded"), errors: []string{"parameter 'DB_MODE' must be one of single, cluster, got 'sharded'"}},
+		{name: "unknown type", typeInfo: TypeInfo{Type: "postgres", Parameters: []ParameterSchema{{Name: "DB_NAME", Type: "date"}}}, parameters: parameters("DB_NAME", "db"), errors: []string{"parameter 'DB_NAME' has unknown type 'date'"}},
+		{name: "unknown parameter", typeInfo: postgres, parameters: parameters("DB_NAME", "db", "DB_USER", "admin"), errors: []string{"unknown parameter 'DB_USER' for type 'postgres'"}},
+		{
+			name:       "several errors",
+			typeInfo:   postgres,
+			parameters: parameters("DB_PORT", "port", "DB_USER", "admin"),
+			errors:     []string{"missing required parameter 'DB_NAME'", "parameter 'DB_PORT' must be of type 'integer', got 'port'", "unknown parameter 'DB_USER' for type 'postgres'"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.typeInfo.ValidateParameters(tt.parameters)
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Errorf("expected parameters to be valid, got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %v, got none", tt.errors)
+			}
+			for _, expected := range tt.errors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error '%s', got: %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	tests := []struct {
+		name       string
+		parameters []v1beta1.NameValuePair
+		expected   []v1beta1.NameValuePair
+	}{
+		{name: "all defaulted", parameters: parameters("DB_NAME", "db"), expected: parameters("DB_NAME", "db", "DB_PORT", "5432", "DB_SSL", "true")},
+		{name: "some defaulted", parameters: parameters("DB_NAME", "db", "DB_PORT", "5433"), expected: parameters("DB_NAME", "db", "DB_PORT", "5433", "DB_SSL", "true")},
+		{name: "none defaulted", parameters: parameters("DB_PORT", "5433", "DB_SSL", "false"), expected: parameters("DB_PORT", "5433", "DB_SSL", "false")},
+		{name: "no parameters", expected: parameters("DB_PORT", "5432", "DB_SSL", "true")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]v1beta1.NameValuePair(nil), tt.parameters...)
+			actual := postgres.WithDefaults(tt.parameters)
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+			if !reflect.DeepEqual(tt.parameters, original) {
+				t.Errorf("expected parameters not to be modified, got %v", tt.parameters)
+			}
+		})
+	}
+}
+
+// validatingResource is a PluginResource recording the Capabilities it was asked to validate
+type validatingResource struct {
+	SimplePluginResourceStem
+	validated []framework.SerializableResource
+}
+
+func (v *validatingResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
+	return nil
+}
+
+func (v *validatingResource) CheckValidity(owner framework.SerializableResource) []string {
+	v.validated = append(v.validated, owner)
+	return []string{"rejected by plugin"}
+}
+
+func TestCheckValidityValidatesOnHostFirst(t *testing.T) {
+	resource := &validatingResource{SimplePluginResourceStem: NewSimplePluginResourceStem("database", postgres)}
+	p, err := NewInProcessPlugin("validating", log.Log, PluginConfig{}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Kill()
+
+	capability := func(version string, parameters []v1beta1.NameValuePair) *halkyon.Capability {
+		c := &halkyon.Capability{Spec: halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: version, Parameters: parameters}}
+		c.Name = "db"
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		capability *halkyon.Capability
+		error      string
+		validated  int
+	}{
+		{name: "invalid parameters", capability: capability("11", parameters("DB_PORT", "port")), error: "missing required parameter 'DB_NAME'"},
+		{name: "unsupported version", capability: capability("9", parameters("DB_NAME", "db")), error: "version '9' of 'postgres' is not supported"},
+		{name: "valid", capability: capability("11", parameters("DB_NAME", "db")), error: "rejected by plugin", validated: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource.validated = nil
+			err := p.CheckValidity(tt.capability)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("expected error '%s', got: %v", tt.error, err)
+			}
+			if len(resource.validated) != tt.validated {
+				t.Errorf("expected plugin to be called %d time(s), got %d", tt.validated, len(resource.validated))
+			}
+		})
+	}
+}
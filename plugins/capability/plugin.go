@@ -17,11 +17,13 @@ type GoPluginPlugin struct {
 }
 
 func (p *GoPluginPlugin) Server(b *plugin.MuxBroker) (interface{}, error) {
-	return &PluginServerImpl{capability: p.Delegate, logger: p.Logger}, nil
+	return &PluginServerImpl{capability: p.Delegate, logger: p.Logger, broker: b}, nil
 }
 
 func (p *GoPluginPlugin) Client(b *plugin.MuxBroker, client *rpc.Client) (interface{}, error) {
-	return &PluginClient{name: p.name, client: client}, nil
+	pluginClient := newPluginClient(p.name, client)
+	pluginClient.broker = b
+	return pluginClient, nil
 }
 
 func GetPluginExecutableName() string {
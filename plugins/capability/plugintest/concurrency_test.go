@@ -0,0 +1,189 @@
+package plugintest
+
+import (
+	"fmt"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/plugins/capability"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sync"
+	"testing"
+	"time"
+)
+
+type credentials struct {
+	owner framework.SerializableResource
+}
+
+func (c credentials) GetDataMap() map[string][]byte {
+	return map[string][]byte{"user": []byte(c.owner.GetName())}
+}
+
+func (c credentials) GetSecretName() string {
+	return c.owner.GetName() + "-credentials"
+}
+
+func (c credentials) Owner() framework.SerializableResource {
+	return c.owner
+}
+
+type databaseResource struct {
+	capability.SimplePluginResourceStem
+}
+
+func (d *databaseResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
+	return []framework.DependentResource{framework.NewSecret(credentials{owner: owner})}
+}
+
+func (d *databaseResource) CheckValidity(owner framework.SerializableResource) []string {
+	return nil
+}
+
+// newManager creates a Manager which doesn't need a cluster as long as its controllers only watch channel sources
+func newManager(t *testing.T, s *runtime.Scheme) manager.Manager {
+	mgr, err := manager.New(&rest.Config{Host: "http://127.0.0.1:1"}, manager.Options{
+		Scheme:             s,
+		MetricsBindAddress: "0",
+		MapperProvider: func(*rest.Config) (meta.RESTMapper, error) {
+			return meta.NewDefaultRESTMapper(nil), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mgr
+}
+
+func TestConcurrentReconciles(t *testing.T) {
+	const capabilities = 50
+	const maxConcurrentReconciles = 8
+
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	resource := &databaseResource{
+		SimplePluginResourceStem: capability.NewSimplePluginResourceStem("database", capability.TypeInfo{Type: "postgres", Versions: []string{"11"}}),
+	}
+	h, err := NewHarness(s, nil, capability.PluginConfig{}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// reconciles wait until the controller runs as many of them as it's allowed to, so that the plugin is used concurrently
+	var mutex sync.Mutex
+	inFlight := 0
+	saturated, saturation := make(chan struct{}), sync.Once{}
+	reconciled := sync.WaitGroup{}
+	reconciled.Add(capabilities)
+	errors := make(chan error, 2*capabilities)
+	reconciler := reconcile.Func(func(request reconcile.Request) (reconcile.Result, error) {
+		defer reconciled.Done()
+		mutex.Lock()
+		if inFlight++; inFlight == maxConcurrentReconciles {
+			saturation.Do(func() { close(saturated) })
+		}
+		mutex.Unlock()
+		defer func() {
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+		}()
+		select {
+		case <-saturated:
+		case <-time.After(10 * time.Second):
+			errors <- fmt.Errorf("'%s': controller didn't run %d reconciles concurrently", request.Name, maxConcurrentReconciles)
+		}
+
+		var i int
+		if _, err := fmt.Sscanf(request.Name, "db-%d", &i); err != nil {
+			errors <- err
+		} else if err := exercise(h, i); err != nil {
+			errors <- err
+		}
+		return reconcile.Result{}, nil
+	})
+
+	mgr := newManager(t, s)
+	c, err := controller.New("capability-controller", mgr, controller.Options{Reconciler: reconciler, MaxConcurrentReconciles: maxConcurrentReconciles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := make(chan event.GenericEvent, capabilities)
+	if err := c.Watch(&source.Channel{Source: requests}, &handler.EnqueueRequestForObject{}); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		if err := mgr.Start(stop); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	for i := 0; i < capabilities; i++ {
+		owner := &halkyon.Capability{ObjectMeta: v1.ObjectMeta{Name: fmt.Sprintf("db-%d", i), Namespace: "test"}}
+		requests <- event.GenericEvent{Meta: owner, Object: owner}
+	}
+	done := make(chan struct{})
+	go func() {
+		reconciled.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("capabilities weren't all reconciled")
+	}
+	close(errors)
+
+	for err := range errors {
+		t.Error(err)
+	}
+}
+
+// exercise exercises the plugin as the operator would when reconciling the i-th Capability, checking that responses are not
+// mixed up with the ones of Capabilities reconciled concurrently
+func exercise(h *Harness, i int) error {
+	owner := &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: fmt.Sprintf("db-%d", i), Namespace: "test"},
+		Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: "11"},
+	}
+	if category := h.Plugin.GetCategory(); category != "database" {
+		return fmt.Errorf("'%s': unexpected category '%s'", owner.Name, category)
+	}
+	if types := h.Plugin.GetTypes(); len(types) != 1 || types[0].Type != "postgres" {
+		return fmt.Errorf("'%s': unexpected types %v", owner.Name, types)
+	}
+	if err := h.CheckValidity(owner); err != nil {
+		return err
+	}
+	dependents := h.ReadyFor(owner)
+	if len(dependents) != 1 {
+		return fmt.Errorf("'%s': expected 1 dependent, got %d", owner.Name, len(dependents))
+	}
+	expected := owner.Name + "-credentials"
+	if name := dependents[0].Name(); name != expected {
+		return fmt.Errorf("'%s': expected dependent named '%s', got '%s'", owner.Name, expected, name)
+	}
+	built, err := h.Build(owner, corev1.SchemeGroupVersion.WithKind("Secret"))
+	if err != nil {
+		return err
+	}
+	if meta, ok := built.(v1.Object); !ok || meta.GetName() != expected {
+		return fmt.Errorf("'%s': expected built secret named '%s', got %v", owner.Name, expected, built)
+	}
+	return nil
+}
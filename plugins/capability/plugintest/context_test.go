@@ -0,0 +1,73 @@
+package plugintest
+
+import (
+	"context"
+	"errors"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/plugins/capability"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"testing"
+	"time"
+)
+
+// slowSecret is a Secret dependent whose Build blocks until it is released
+type slowSecret struct {
+	framework.Secret
+	release chan struct{}
+}
+
+func (s slowSecret) Build(empty bool) (runtime.Object, error) {
+	if !empty {
+		<-s.release
+	}
+	return s.Secret.Build(empty)
+}
+
+type slowResource struct {
+	capability.SimplePluginResourceStem
+	release chan struct{}
+}
+
+func (s *slowResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
+	return []framework.DependentResource{slowSecret{Secret: framework.NewSecret(credentials{owner: owner}), release: s.release}}
+}
+
+func (s *slowResource) CheckValidity(owner framework.SerializableResource) []string {
+	return nil
+}
+
+func TestCallsGiveUpWhenContextIsDone(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	resource := &slowResource{
+		SimplePluginResourceStem: capability.NewSimplePluginResourceStem("database", capability.TypeInfo{Type: "postgres"}),
+		release:                  release,
+	}
+	h, err := NewHarness(s, nil, capability.PluginConfig{}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	defer close(release)
+
+	owner := &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: "db", Namespace: "test"},
+		Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres"},
+	}
+	dependent, err := h.Dependent(owner, corev1.SchemeGroupVersion.WithKind("Secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := framework.WithContext(dependent).BuildWithContext(ctx, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected build to give up with %v, got %v", context.DeadlineExceeded, err)
+	}
+}
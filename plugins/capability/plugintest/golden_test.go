@@ -0,0 +1,32 @@
+package plugintest
+
+import (
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/operator-framework/frameworktest"
+	"halkyon.io/operator-framework/plugins/capability"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"testing"
+)
+
+func TestPluginDependentsGolden(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	resource := &databaseResource{
+		SimplePluginResourceStem: capability.NewSimplePluginResourceStem("database", capability.TypeInfo{Type: "postgres", Versions: []string{"11"}}),
+	}
+	h, err := NewHarness(s, nil, capability.PluginConfig{}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	owner := &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: "db", Namespace: "test"},
+		Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: "11"},
+	}
+	frameworktest.AssertDependentsGolden(t, "testdata/golden/database.yaml", h.ReadyFor(owner)...)
+}
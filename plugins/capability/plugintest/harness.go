@@ -0,0 +1,139 @@
+/*
+Package plugintest provides the infrastructure to test capability plugins in-process, without having to build and launch a plugin
+binary.
+*/
+package plugintest
+
+import (
+	"fmt"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/plugins/capability"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"testing"
+)
+
+// Harness wires PluginResources to a host-side Plugin in-process, backed by a fake Kubernetes client, so that plugins can be
+// exercised exactly as the operator would, including the serialization of requests and responses.
+type Harness struct {
+	// Plugin is the host-side view of the tested PluginResources
+	Plugin capability.Plugin
+	// Client is the fake Kubernetes client backing the harness
+	Client client.Client
+	// Scheme is the scheme used by the fake Kubernetes client
+	Scheme *runtime.Scheme
+	// Helper is the K8SHelper backed by the fake cluster, used by the dependents the plugin provides and to serve the plugin's
+	// cluster access
+	Helper *framework.K8SHelper
+}
+
+// NewHarness creates a new Harness for the specified PluginResources. The fake cluster uses the given scheme and is initialized
+// with the specified objects. The Harness doesn't use the framework's default Helper, overriding the Helper of the specified
+// PluginConfig, so that tests can run in parallel.
+func NewHarness(scheme *runtime.Scheme, objects []runtime.Object, config capability.PluginConfig, resources ...capability.PluginResource) (*Harness, error) {
+	fakeClient := fake.NewFakeClientWithScheme(scheme, objects...)
+	helper := &framework.K8SHelper{Client: fakeClient, Scheme: scheme}
+	config.Helper = helper
+	p, err := capability.NewInProcessPlugin("plugintest", log.Log.WithName("plugintest"), config, resources...)
+	if err != nil {
+		return nil, err
+	}
+	return &Harness{Plugin: p, Client: fakeClient, Scheme: scheme, Helper: helper}, nil
+}
+
+// Close releases the resources associated with this Harness
+func (h *Harness) Close() {
+	h.Plugin.Kill()
+}
+
+// ReadyFor returns the DependentResources the plugin provides for the specified Capability, as the operator would see them,
+// set up to use the Harness' Helper
+func (h *Harness) ReadyFor(owner *halkyon.Capability) []framework.DependentResource {
+	dependents := h.Plugin.ReadyFor(owner)
+	for _, dependent := range dependents {
+		if aware, ok := dependent.(framework.HelperAware); ok {
+			aware.SetHelper(h.Helper)
+		}
+	}
+	return dependents
+}
+
+// CheckValidity checks the validity of the specified Capability according to the plugin
+func (h *Harness) CheckValidity(owner *halkyon.Capability) error {
+	return h.Plugin.CheckValidity(owner)
+}
+
+// Dependent retrieves the DependentResource with the specified GroupVersionKind the plugin provides for the given Capability
+func (h *Harness) Dependent(owner *halkyon.Capability, gvk schema.GroupVersionKind) (framework.DependentResource, error) {
+	for _, dependent := range h.ReadyFor(owner) {
+		if dependent.GetConfig().GroupVersionKind == gvk {
+			return dependent, nil
+		}
+	}
+	return nil, fmt.Errorf("no dependent of type %v for capability '%s'", gvk, owner.Name)
+}
+
+// Build builds the dependent with the specified GroupVersionKind for the given Capability
+func (h *Harness) Build(owner *halkyon.Capability, gvk schema.GroupVersionKind) (runtime.Object, error) {
+	dependent, err := h.Dependent(owner, gvk)
+	if err != nil {
+		return nil, err
+	}
+	return dependent.Build(false)
+}
+
+// Update calls Update on the dependent with the specified GroupVersionKind for the given Capability, passing it the specified
+// object
+func (h *Harness) Update(owner *halkyon.Capability, gvk schema.GroupVersionKind, toUpdate runtime.Object) (bool, runtime.Object, error) {
+	dependent, err := h.Dependent(owner, gvk)
+	if err != nil {
+		return false, nil, err
+	}
+	return dependent.Update(toUpdate)
+}
+
+// GetCondition retrieves the condition of the dependent with the specified GroupVersionKind for the given Capability, based on
+// the specified underlying object and error
+func (h *Harness) GetCondition(owner *halkyon.Capability, gvk schema.GroupVersionKind, underlying runtime.Object, err error) (*v1beta1.DependentCondition, error) {
+	dependent, e := h.Dependent(owner, gvk)
+	if e != nil {
+		return nil, e
+	}
+	return dependent.GetCondition(underlying, err), nil
+}
+
+// Outputs retrieves the outputs the plugin provides for the specified Capability
+func (h *Harness) Outputs(owner *halkyon.Capability) ([]v1beta1.NameValuePair, error) {
+	return h.Plugin.GetOutputs(owner)
+}
+
+// Finalize calls the deletion and finalization hooks of the plugin for the specified Capability, as the operator would when the
+// Capability is deleted
+func (h *Harness) Finalize(owner *halkyon.Capability) error {
+	return capability.Finalize(h.Plugin, owner)
+}
+
+// CreateOrUpdate creates or updates the dependents of the specified Capability on the fake cluster, in order, as the operator
+// would
+func (h *Harness) CreateOrUpdate(owner *halkyon.Capability) error {
+	for _, dependent := range h.ReadyFor(owner) {
+		if err := framework.CreateOrUpdate(dependent); err != nil {
+			return fmt.Errorf("failed to create or update '%s' %s: %w", dependent.Name(), dependent.GetConfig().TypeName, err)
+		}
+	}
+	return nil
+}
+
+// AssertConformance checks that the specified PluginResource conforms to the plugins architecture contract when exercised with
+// the given sample Capabilities, reporting each violation as a test error
+func AssertConformance(t *testing.T, resource capability.PluginResource, scheme *runtime.Scheme, samples ...*halkyon.Capability) {
+	t.Helper()
+	for _, violation := range capability.CheckConformance(resource, scheme, samples...) {
+		t.Error(violation.Error())
+	}
+}
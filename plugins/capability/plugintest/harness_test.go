@@ -0,0 +1,87 @@
+package plugintest
+
+import (
+	"context"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/plugins/capability"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"testing"
+)
+
+func newDatabaseHarness(t *testing.T) *Harness {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := halkyon.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	resource := &databaseResource{
+		SimplePluginResourceStem: capability.NewSimplePluginResourceStem("database", capability.TypeInfo{Type: "postgres", Versions: []string{"11"}}),
+	}
+	h, err := NewHarness(s, nil, capability.PluginConfig{}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHarnessesAreIsolated(t *testing.T) {
+	names := []string{"db-a", "db-b", "db-c", "db-d"}
+	for _, name := range names {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			h := newDatabaseHarness(t)
+			defer h.Close()
+
+			owner := &halkyon.Capability{
+				ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test"},
+				Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: "11"},
+			}
+			if err := h.CreateOrUpdate(owner); err != nil {
+				t.Fatal(err)
+			}
+			for _, other := range names {
+				err := h.Client.Get(context.TODO(), types.NamespacedName{Name: other + "-credentials", Namespace: "test"}, &corev1.Secret{})
+				if other == name && err != nil {
+					t.Errorf("expected '%s' secret to be created, got %v", other, err)
+				}
+				if other != name && !errors.IsNotFound(err) {
+					t.Errorf("didn't expect '%s' secret to be created on this harness' cluster, got %v", other, err)
+				}
+			}
+		})
+	}
+	t.Run("default helper untouched", func(t *testing.T) {
+		t.Parallel()
+		if framework.Helper.Client != nil {
+			t.Error("expected harnesses not to set up the default Helper")
+		}
+	})
+}
+
+func TestHarnessDependentsUseHarnessHelper(t *testing.T) {
+	h := newDatabaseHarness(t)
+	defer h.Close()
+
+	owner := &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: "db", Namespace: "test"},
+		Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: "11"},
+	}
+	for _, dependent := range h.ReadyFor(owner) {
+		aware, ok := dependent.(framework.HelperAware)
+		if !ok {
+			t.Fatalf("expected '%s' dependent to be HelperAware", dependent.Name())
+		}
+		if aware.GetHelper() != h.Helper {
+			t.Errorf("expected '%s' dependent to use the harness' helper", dependent.Name())
+		}
+	}
+}
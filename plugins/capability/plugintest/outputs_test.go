@@ -0,0 +1,47 @@
+package plugintest
+
+import (
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"halkyon.io/operator-framework/plugins/capability"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"testing"
+)
+
+var _ capability.OutputsProvider = &databaseResource{}
+
+// GetOutputs publishes the name of the secret holding the credentials of the database
+func (d *databaseResource) GetOutputs(owner framework.SerializableResource) []v1beta1.NameValuePair {
+	return []v1beta1.NameValuePair{{Name: capability.SecretNameOutput, Value: credentials{owner: owner}.GetSecretName()}}
+}
+
+func TestOutputsFor(t *testing.T) {
+	h := newDatabaseHarness(t)
+	defer h.Close()
+	registry := capability.NewRegistry(log.Log)
+	registry.SetOffline(true)
+	if err := registry.Register(h.Plugin); err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &halkyon.Capability{
+		ObjectMeta: v1.ObjectMeta{Name: "db", Namespace: "test"},
+		Spec:       halkyon.CapabilitySpec{Category: "database", Type: "postgres", Version: "11"},
+	}
+	outputs, err := registry.OutputsFor(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []v1beta1.NameValuePair{{Name: capability.SecretNameOutput, Value: "db-credentials"}}
+	if !reflect.DeepEqual(outputs, expected) {
+		t.Errorf("expected outputs %v, got %v", expected, outputs)
+	}
+
+	owner.Spec.Type = "mysql"
+	if _, err := registry.OutputsFor(owner); err == nil {
+		t.Error("expected retrieving outputs of capability without plugin to fail")
+	}
+}
@@ -0,0 +1,209 @@
+package capability
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	"halkyon.io/api/capability-info/v1beta1"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// OwnerLabelPrefix prefixes the labels recording which operator instances published a CapabilityInfo, if their Registry was
+	// given an identifier using SetOwnerID. Each instance adds its own label, named using OwnerLabelPrefix followed by its
+	// identifier, so that instances publishing the same CapabilityInfo don't overwrite each other's ownership.
+	OwnerLabelPrefix = "owner.halkyon.io/"
+	// MissingSinceAnnotation is the annotation recording, in RFC 3339 format, since when no plugin handles the category/type
+	// pair of a CapabilityInfo. It is used to only purge CapabilityInfos after a grace period.
+	MissingSinceAnnotation = "halkyon.io/missing-since"
+)
+
+// PurgeOptions configures how PurgeCapabilityInfosWith purges CapabilityInfos which are not handled by any Plugin anymore
+type PurgeOptions struct {
+	// DryRun only reports the CapabilityInfos which would be purged, without modifying anything
+	DryRun bool
+	// OwnedOnly restricts purging to the CapabilityInfos published by this Registry's operator instance, as identified by its
+	// owner label (see OwnerLabelPrefix). It requires an owner identifier to have been set using SetOwnerID.
+	OwnedOnly bool
+	// GracePeriod is the duration during which a CapabilityInfo without plugin is kept, in case the plugin is only temporarily
+	// missing (e.g. while it's being upgraded). Without grace period, CapabilityInfos are purged as soon as their plugin is missing.
+	GracePeriod time.Duration
+}
+
+// PurgeResult reports what happened to the CapabilityInfos considered for purging, identified by their name
+type PurgeResult struct {
+	// Purged lists the CapabilityInfos which were deleted or, in dry-run mode, which would have been
+	Purged []string
+	// Pending lists the CapabilityInfos without plugin which are kept until their grace period expires
+	Pending []string
+	// Released lists the CapabilityInfos which, when only purging owned CapabilityInfos, are also owned by other operator
+	// instances: they are not deleted, only the owner label of this Registry's operator instance is removed (or, in dry-run
+	// mode, would have been)
+	Released []string
+	// Failed records the error which occurred for each of the CapabilityInfos which couldn't be processed
+	Failed map[string]error
+}
+
+// Err aggregates the errors recorded in this PurgeResult, if any
+func (r PurgeResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(r.Failed))
+	for name, err := range r.Failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, err.Error()))
+	}
+	sort.Strings(msgs)
+	return fmt.Errorf("couldn't purge CapabilityInfo(s): %s", strings.Join(msgs, ", "))
+}
+
+// SetOwnerID sets the identifier of the operator instance using this Registry, which is then recorded in the published
+// CapabilityInfos using a label named after it, see OwnerLabelPrefix. The identifier must therefore be a valid label name.
+func (r *Registry) SetOwnerID(id string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ownerID = id
+}
+
+// PurgeCapabilityInfos removes the CapabilityInfos which are not handled by any Plugin of this Registry anymore, returning the
+// number of CapabilityInfos which were actually deleted. Nothing is purged if this Registry is offline. See
+// PurgeCapabilityInfosWith for more control over the process.
+func (r *Registry) PurgeCapabilityInfos(log logr.Logger) (purgedCount int, err error) {
+	result, err := r.PurgeCapabilityInfosWith(log, PurgeOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return len(result.Purged), result.Err()
+}
+
+// PurgeCapabilityInfosWith purges the CapabilityInfos which are not handled by any Plugin of this Registry anymore according to
+// the specified PurgeOptions. An error is only returned if the CapabilityInfos couldn't be listed, failures to process individual
+// CapabilityInfos being recorded in the returned PurgeResult. Nothing is purged if this Registry is offline.
+func (r *Registry) PurgeCapabilityInfosWith(log logr.Logger, options PurgeOptions) (PurgeResult, error) {
+	result := PurgeResult{Failed: make(map[string]error, 7)}
+	capInfoClient, stale := r.capabilityInfos()
+	if stale {
+		// publish the CapabilityInfos of the plugins registered while offline so that they're not purged
+		r.publishWith(capInfoClient, r.pairs())
+	}
+	r.mutex.RLock()
+	ownerID := r.ownerID
+	r.mutex.RUnlock()
+	if capInfoClient == nil {
+		return result, nil
+	}
+
+	listOptions := v1.ListOptions{}
+	if options.OwnedOnly {
+		if len(ownerID) == 0 {
+			return result, fmt.Errorf("cannot only purge owned CapabilityInfos without an owner identifier")
+		}
+		listOptions.LabelSelector = ownerLabel(ownerID)
+	}
+	existing, err := capInfoClient.List(listOptions)
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now()
+	for i := range existing.Items {
+		info := &existing.Items[i]
+		if _, err := r.GetPluginFor(halkyon.CapabilityCategory(info.Spec.Category), halkyon.CapabilityType(info.Spec.Type)); err == nil {
+			continue
+		}
+
+		// CapabilityInfos still owned by other operator instances are left for them to handle
+		if options.OwnedOnly && ownedByOthers(info, ownerID) {
+			if !options.DryRun {
+				if err := release(capInfoClient, info, ownerID); err != nil {
+					result.Failed[info.Name] = err
+					continue
+				}
+			}
+			result.Released = append(result.Released, info.Name)
+			continue
+		}
+
+		// plugin for info doesn't exist, so we should remove it once the grace period is over
+		if options.GracePeriod > 0 {
+			missingSince, err := missingSince(info)
+			if err != nil {
+				result.Failed[info.Name] = err
+				continue
+			}
+			if missingSince == nil {
+				if !options.DryRun {
+					if err := markMissing(capInfoClient, info, now); err != nil {
+						result.Failed[info.Name] = err
+						continue
+					}
+				}
+				result.Pending = append(result.Pending, info.Name)
+				continue
+			}
+			if now.Sub(*missingSince) < options.GracePeriod {
+				result.Pending = append(result.Pending, info.Name)
+				continue
+			}
+		}
+
+		if options.DryRun {
+			log.Info(fmt.Sprintf("would purge %s CapabilityInfo", info.Name))
+		} else {
+			if err := capInfoClient.Delete(info.Name, v1.NewDeleteOptions(0)); err != nil {
+				result.Failed[info.Name] = err
+				continue
+			}
+			log.Info(fmt.Sprintf("purged %s CapabilityInfo", info.Name))
+		}
+		result.Purged = append(result.Purged, info.Name)
+	}
+	return result, nil
+}
+
+// ownerLabel returns the name of the label recording that the operator instance with the specified identifier published a
+// CapabilityInfo
+func ownerLabel(ownerID string) string {
+	return OwnerLabelPrefix + ownerID
+}
+
+// ownedByOthers checks whether the specified CapabilityInfo is owned by operator instances other than the specified one
+func ownedByOthers(info *v1beta1.CapabilityInfo, ownerID string) bool {
+	for label := range info.Labels {
+		if strings.HasPrefix(label, OwnerLabelPrefix) && label != ownerLabel(ownerID) {
+			return true
+		}
+	}
+	return false
+}
+
+// release removes the owner label of the operator instance with the specified identifier from the given CapabilityInfo
+func release(client CapabilityInfoClient, info *v1beta1.CapabilityInfo, ownerID string) error {
+	delete(info.Labels, ownerLabel(ownerID))
+	_, err := client.Update(info)
+	return err
+}
+
+func missingSince(info *v1beta1.CapabilityInfo) (*time.Time, error) {
+	value, ok := info.Annotations[MissingSinceAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %v", MissingSinceAnnotation, err)
+	}
+	return &since, nil
+}
+
+func markMissing(client CapabilityInfoClient, info *v1beta1.CapabilityInfo, now time.Time) error {
+	if info.Annotations == nil {
+		info.Annotations = make(map[string]string, 1)
+	}
+	info.Annotations[MissingSinceAnnotation] = now.Format(time.RFC3339)
+	_, err := client.Update(info)
+	return err
+}
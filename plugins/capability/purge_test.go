@@ -0,0 +1,211 @@
+package capability
+
+import (
+	"fmt"
+	capinfo "halkyon.io/api/capability-info/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCapabilityInfo(name, category, capabilityType string, annotations, labels map[string]string) capinfo.CapabilityInfo {
+	return capinfo.CapabilityInfo{
+		ObjectMeta: v1.ObjectMeta{Name: name, Annotations: annotations, Labels: labels},
+		Spec:       capinfo.CapabilityInfoSpec{Category: category, Type: capabilityType},
+	}
+}
+
+func missingFor(d time.Duration) map[string]string {
+	return map[string]string{MissingSinceAnnotation: time.Now().Add(-d).Format(time.RFC3339)}
+}
+
+// newPurgeRegistry creates a Registry in which a postgres plugin is registered, publishing CapabilityInfos using a fake client
+// initialized with the specified CapabilityInfos
+func newPurgeRegistry(t *testing.T, infos ...capinfo.CapabilityInfo) (*Registry, *fakeCapabilityInfos) {
+	client := newFakeCapabilityInfos(infos...)
+	registry := NewRegistry(log.Log)
+	registry.SetCapabilityInfoClient(client)
+	if err := registry.Register(newStubPlugin("postgres-plugin", "database", "postgres", "11")); err != nil {
+		t.Fatal(err)
+	}
+	return registry, client
+}
+
+// remaining returns the sorted names of the CapabilityInfos known to the specified client
+func (f *fakeCapabilityInfos) remaining() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	names := make([]string, 0, len(f.infos))
+	for name := range f.infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sorted(names []string) []string {
+	names = append([]string{}, names...)
+	sort.Strings(names)
+	return names
+}
+
+func TestPurgeCapabilityInfos(t *testing.T) {
+	registry, client := newPurgeRegistry(t,
+		newCapabilityInfo("database-postgres", "database", "postgres", nil, nil),
+		newCapabilityInfo("database-mysql", "database", "mysql", nil, nil),
+		newCapabilityInfo("cache-redis", "cache", "redis", nil, nil),
+	)
+
+	count, err := registry.PurgeCapabilityInfos(log.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 CapabilityInfos to be purged, got %d", count)
+	}
+	if remaining := client.remaining(); !reflect.DeepEqual(remaining, []string{"database-postgres"}) {
+		t.Errorf("expected only handled CapabilityInfo to remain, got %v", remaining)
+	}
+}
+
+func TestPurgeCapabilityInfosDryRun(t *testing.T) {
+	registry, client := newPurgeRegistry(t,
+		newCapabilityInfo("database-mysql", "database", "mysql", nil, nil),
+		newCapabilityInfo("cache-redis", "cache", "redis", nil, nil),
+	)
+
+	for _, gracePeriod := range []time.Duration{0, time.Hour} {
+		result, err := registry.PurgeCapabilityInfosWith(log.Log, PurgeOptions{DryRun: true, GracePeriod: gracePeriod})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gracePeriod == 0 && !reflect.DeepEqual(sorted(result.Purged), []string{"cache-redis", "database-mysql"}) {
+			t.Errorf("expected orphaned CapabilityInfos to be reported as purged, got %v", result.Purged)
+		}
+		if gracePeriod > 0 && !reflect.DeepEqual(sorted(result.Pending), []string{"cache-redis", "database-mysql"}) {
+			t.Errorf("expected orphaned CapabilityInfos to be reported as pending, got %v", result.Pending)
+		}
+		if remaining := client.remaining(); !reflect.DeepEqual(remaining, []string{"cache-redis", "database-mysql", "database-postgres"}) {
+			t.Errorf("expected nothing to be deleted in dry-run mode, got %v remaining", remaining)
+		}
+		for _, name := range []string{"cache-redis", "database-mysql"} {
+			if info, _ := client.get(name); len(info.Annotations[MissingSinceAnnotation]) > 0 {
+				t.Errorf("expected '%s' CapabilityInfo not to be marked as missing in dry-run mode", name)
+			}
+		}
+	}
+}
+
+func TestPurgeCapabilityInfosGracePeriod(t *testing.T) {
+	registry, client := newPurgeRegistry(t,
+		newCapabilityInfo("database-mysql", "database", "mysql", nil, nil),
+		newCapabilityInfo("database-mongo", "database", "mongo", missingFor(time.Minute), nil),
+		newCapabilityInfo("cache-redis", "cache", "redis", missingFor(2*time.Hour), nil),
+		newCapabilityInfo("cache-memcached", "cache", "memcached", map[string]string{MissingSinceAnnotation: "yesterday"}, nil),
+	)
+
+	result, err := registry.PurgeCapabilityInfosWith(log.Log, PurgeOptions{GracePeriod: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Purged, []string{"cache-redis"}) {
+		t.Errorf("expected only CapabilityInfo missing for longer than the grace period to be purged, got %v", result.Purged)
+	}
+	if pending := sorted(result.Pending); !reflect.DeepEqual(pending, []string{"database-mongo", "database-mysql"}) {
+		t.Errorf("expected recently orphaned CapabilityInfos to be pending, got %v", pending)
+	}
+	if err, ok := result.Failed["cache-memcached"]; !ok || !strings.Contains(err.Error(), "invalid "+MissingSinceAnnotation+" annotation") {
+		t.Errorf("expected CapabilityInfo with invalid annotation to be reported as failed, got %v", result.Failed)
+	}
+	if remaining := client.remaining(); !reflect.DeepEqual(remaining, []string{"cache-memcached", "database-mongo", "database-mysql", "database-postgres"}) {
+		t.Errorf("expected recent orphans to be kept, got %v remaining", remaining)
+	}
+	if info, _ := client.get("database-mysql"); len(info.Annotations[MissingSinceAnnotation]) == 0 {
+		t.Error("expected newly orphaned CapabilityInfo to be marked as missing")
+	}
+	if err := result.Err(); err == nil || !strings.Contains(err.Error(), "cache-memcached") {
+		t.Errorf("expected aggregated error to mention failed CapabilityInfo, got %v", err)
+	}
+}
+
+func TestPurgeCapabilityInfosOwnedOnly(t *testing.T) {
+	owners := func(ids ...string) map[string]string {
+		labels := make(map[string]string, len(ids))
+		for _, id := range ids {
+			labels[ownerLabel(id)] = "true"
+		}
+		return labels
+	}
+	registry, client := newPurgeRegistry(t,
+		newCapabilityInfo("database-mysql", "database", "mysql", nil, owners("operator-1")),
+		newCapabilityInfo("database-mongo", "database", "mongo", nil, owners("operator-1", "operator-2")),
+		newCapabilityInfo("cache-redis", "cache", "redis", nil, owners("operator-2")),
+		newCapabilityInfo("cache-memcached", "cache", "memcached", nil, nil),
+	)
+
+	if _, err := registry.PurgeCapabilityInfosWith(log.Log, PurgeOptions{OwnedOnly: true}); err == nil {
+		t.Error("expected purging owned CapabilityInfos to fail without owner identifier")
+	}
+
+	registry.SetOwnerID("operator-1")
+	result, err := registry.PurgeCapabilityInfosWith(log.Log, PurgeOptions{OwnedOnly: true, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Purged, []string{"database-mysql"}) || !reflect.DeepEqual(result.Released, []string{"database-mongo"}) {
+		t.Errorf("expected owned orphan to be reported as purged and shared one as released, got %v and %v", result.Purged, result.Released)
+	}
+	if info, _ := client.get("database-mongo"); !reflect.DeepEqual(info.Labels, owners("operator-1", "operator-2")) {
+		t.Errorf("expected shared CapabilityInfo not to be released in dry-run mode, got labels %v", info.Labels)
+	}
+
+	result, err = registry.PurgeCapabilityInfosWith(log.Log, PurgeOptions{OwnedOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Purged, []string{"database-mysql"}) || !reflect.DeepEqual(result.Released, []string{"database-mongo"}) {
+		t.Errorf("expected owned orphan to be purged and shared one to be released, got %v and %v", result.Purged, result.Released)
+	}
+	if remaining := client.remaining(); !reflect.DeepEqual(remaining, []string{"cache-memcached", "cache-redis", "database-mongo", "database-postgres"}) {
+		t.Errorf("expected CapabilityInfos owned by others to be kept, got %v remaining", remaining)
+	}
+	if info, _ := client.get("database-mongo"); !reflect.DeepEqual(info.Labels, owners("operator-2")) {
+		t.Errorf("expected shared CapabilityInfo to only be owned by the other operator instance anymore, got labels %v", info.Labels)
+	}
+}
+
+func TestPurgeCapabilityInfosReportsFailures(t *testing.T) {
+	registry, client := newPurgeRegistry(t,
+		newCapabilityInfo("database-mysql", "database", "mysql", nil, nil),
+		newCapabilityInfo("cache-redis", "cache", "redis", nil, nil),
+	)
+	client.deleteErrors = map[string]error{"cache-redis": fmt.Errorf("forbidden")}
+
+	count, err := registry.PurgeCapabilityInfos(log.Log)
+	if count != 1 {
+		t.Errorf("expected only deleted CapabilityInfos to be counted, got %d", count)
+	}
+	if err == nil || !strings.Contains(err.Error(), "cache-redis: forbidden") {
+		t.Errorf("expected error to report failed CapabilityInfo, got %v", err)
+	}
+	if remaining := client.remaining(); !reflect.DeepEqual(remaining, []string{"cache-redis", "database-postgres"}) {
+		t.Errorf("expected failed CapabilityInfo to remain, got %v", remaining)
+	}
+}
+
+func TestPurgeCapabilityInfosOffline(t *testing.T) {
+	registry, client := newPurgeRegistry(t, newCapabilityInfo("database-mysql", "database", "mysql", nil, nil))
+	registry.SetOffline(true)
+
+	count, err := registry.PurgeCapabilityInfos(log.Log)
+	if err != nil || count != 0 {
+		t.Errorf("expected nothing to be purged while offline, got %d (%v)", count, err)
+	}
+	if remaining := client.remaining(); !reflect.DeepEqual(remaining, []string{"database-mysql", "database-postgres"}) {
+		t.Errorf("expected nothing to be deleted while offline, got %v remaining", remaining)
+	}
+}
@@ -6,27 +6,278 @@ import (
 	"halkyon.io/api/capability-info/clientset/versioned"
 	"halkyon.io/api/capability-info/v1beta1"
 	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	controllerruntime "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
 	"strings"
+	"sync"
 )
 
-type typeRegistry map[halkyon.CapabilityType]Plugin
+type typeRegistry map[halkyon.CapabilityType][]Plugin
 type pluginsRegistry map[halkyon.CapabilityCategory]typeRegistry
 
-var plugins pluginsRegistry
-var capInfoClient = versioned.NewForConfigOrDie(controllerruntime.GetConfigOrDie()).HalkyonV1beta1().CapabilityInfos()
+// CapabilityInfoClient is the subset of the CapabilityInfo client a Registry needs to publish CapabilityInfos
+type CapabilityInfoClient interface {
+	Get(name string, options v1.GetOptions) (*v1beta1.CapabilityInfo, error)
+	List(opts v1.ListOptions) (*v1beta1.CapabilityInfoList, error)
+	Create(*v1beta1.CapabilityInfo) (*v1beta1.CapabilityInfo, error)
+	Update(*v1beta1.CapabilityInfo) (*v1beta1.CapabilityInfo, error)
+	Delete(name string, options *v1.DeleteOptions) error
+}
+
+// RegistryEventType identifies the kind of change that occurred in a Registry
+type RegistryEventType string
+
+const (
+	// PluginRegistered signals that a Plugin was registered to handle a category/type pair
+	PluginRegistered RegistryEventType = "Registered"
+	// PluginUnregistered signals that a Plugin doesn't handle a category/type pair anymore
+	PluginUnregistered RegistryEventType = "Unregistered"
+	// PluginShadowed signals that a Plugin doesn't handle a category/type pair because it lost a conflict with another Plugin
+	PluginShadowed RegistryEventType = "Shadowed"
+)
+
+// RegistryEvent describes a change that occurred in a Registry
+type RegistryEvent struct {
+	Type     RegistryEventType
+	Plugin   Plugin
+	Category halkyon.CapabilityCategory
+	CapType  halkyon.CapabilityType
+}
+
+// RegistryListener is notified of the changes occurring in a Registry it subscribed to
+type RegistryListener func(event RegistryEvent)
+
+// Registry records which Plugins handle which category/type pairs. A Registry is safe for concurrent use.
+type Registry struct {
+	mutex      sync.RWMutex
+	publishing sync.Mutex
+	plugins    pluginsRegistry
+	shadowed   pluginsRegistry
+	resolution ConflictResolution
+	listeners  []RegistryListener
+	log        logr.Logger
+	ownerID    string
+	helper     *framework.K8SHelper
+	capInfos   CapabilityInfoClient
+	offline    bool
+	// infosFrom is the configuration capInfos was lazily created from, nil if capInfos was set explicitly
+	infosFrom     *rest.Config
+	loggedOffline bool
+	// stale records that CapabilityInfos couldn't be published because no client was available
+	stale bool
+}
+
+// DefaultRegistry is the Registry used by the package-level functions and in which plugins are registered unless otherwise
+// specified in their PluginConfig
+var DefaultRegistry = NewRegistry(log.Log.WithName("capability-plugins"))
+
+// NewRegistry creates a new, empty, Registry using the specified logger and the FirstWins ConflictPolicy
+func NewRegistry(log logr.Logger) *Registry {
+	return NewConfiguredRegistry(log, ConflictResolution{Policy: FirstWins})
+}
+
+// NewConfiguredRegistry creates a new, empty, Registry using the specified logger and ConflictResolution
+func NewConfiguredRegistry(log logr.Logger, resolution ConflictResolution) *Registry {
+	return &Registry{plugins: make(pluginsRegistry, 7), shadowed: make(pluginsRegistry, 7), resolution: resolution, log: log}
+}
+
+var _ framework.HelperAware = &Registry{}
+
+// GetHelper returns the K8SHelper which configuration this Registry uses to create its CapabilityInfo client, the framework's
+// Helper unless SetHelper was called
+func (r *Registry) GetHelper() *framework.K8SHelper {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.helperOrDefault()
+}
+
+// SetHelper sets the K8SHelper which configuration this Registry uses to create its CapabilityInfo client
+func (r *Registry) SetHelper(helper *framework.K8SHelper) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.helper = helper
+}
+
+func (r *Registry) helperOrDefault() *framework.K8SHelper {
+	if r.helper == nil {
+		return &framework.Helper
+	}
+	return r.helper
+}
 
+// SetCapabilityInfoClient sets the client this Registry uses to publish CapabilityInfos instead of lazily creating one from its
+// K8SHelper's configuration. Passing nil reverts to the lazily created client.
+func (r *Registry) SetCapabilityInfoClient(client CapabilityInfoClient) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.capInfos = client
+	r.infosFrom = nil
+}
+
+// SetOffline switches this Registry to (or from) offline mode, in which CapabilityInfos are neither published nor purged. A
+// Registry is also offline as long as no CapabilityInfo client was set and its K8SHelper has no configuration to create one
+// from, which is typically the case in plugin binaries and unit tests.
+func (r *Registry) SetOffline(offline bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.offline = offline
+}
+
+// capabilityInfos retrieves the CapabilityInfoClient to use, or nil if this Registry is offline, also returning whether some
+// CapabilityInfos couldn't be published since the previous call because no client was available. It must be called without
+// holding the Registry's lock.
+func (r *Registry) capabilityInfos() (CapabilityInfoClient, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	capInfoClient := r.resolveCapabilityInfos()
+	if capInfoClient == nil {
+		r.stale = true
+		return nil, false
+	}
+	stale := r.stale
+	r.stale = false
+	return capInfoClient, stale
+}
+
+// resolveCapabilityInfos resolves the CapabilityInfoClient to use, if any. Unless a client was set explicitly, the K8SHelper's
+// configuration is checked on each call so that a client is created as soon as a configuration is available, and re-created if
+// it changes. It must be called while holding the Registry's lock.
+func (r *Registry) resolveCapabilityInfos() CapabilityInfoClient {
+	if r.offline {
+		return nil
+	}
+	if r.capInfos != nil && r.infosFrom == nil {
+		return r.capInfos
+	}
+	config := r.helperOrDefault().Config
+	if config == nil {
+		if !r.loggedOffline {
+			r.log.Info("no Kubernetes configuration available, CapabilityInfos won't be published until there is one")
+			r.loggedOffline = true
+		}
+		return nil
+	}
+	if r.capInfos == nil || r.infosFrom != config {
+		clientset, err := versioned.NewForConfig(config)
+		if err != nil {
+			r.log.Error(err, "couldn't create CapabilityInfo client, CapabilityInfos won't be published")
+			return nil
+		}
+		r.capInfos, r.infosFrom, r.loggedOffline = clientset.HalkyonV1beta1().CapabilityInfos(), config, false
+	}
+	return r.capInfos
+}
+
+// SetConflictResolution changes the ConflictResolution used by this Registry. Already registered Plugins are not affected.
+func (r *Registry) SetConflictResolution(resolution ConflictResolution) error {
+	if err := resolution.validate(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resolution = resolution
+	return nil
+}
+
+// GetPluginFor retrieves the Plugin handling the specified category and type pair in the DefaultRegistry
 func GetPluginFor(category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType) (Plugin, error) {
-	if types, ok := plugins[categoryKey(category)]; ok {
-		if p, ok := types[typeKey(capabilityType)]; ok {
-			return p, nil
+	return DefaultRegistry.GetPluginFor(category, capabilityType)
+}
+
+// GetPluginForVersion retrieves the Plugin handling the specified category and type pair for the given version in the
+// DefaultRegistry
+func GetPluginForVersion(category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType, version string) (Plugin, error) {
+	return DefaultRegistry.GetPluginForVersion(category, capabilityType, version)
+}
+
+// GetPluginForCapability retrieves the Plugin handling the specified Capability in the DefaultRegistry
+func GetPluginForCapability(capability *halkyon.Capability) (Plugin, error) {
+	return DefaultRegistry.GetPluginForCapability(capability)
+}
+
+// PurgeCapabilityInfos removes the CapabilityInfos which are not handled by any Plugin of the DefaultRegistry anymore
+func PurgeCapabilityInfos(log logr.Logger) (purgedCount int, err error) {
+	return DefaultRegistry.PurgeCapabilityInfos(log)
+}
+
+// GetPluginFor retrieves the Plugin handling the specified category and type pair, regardless of the version of the
+// capability. If several plugins support the pair for different versions, the first one to have been registered is returned.
+func (r *Registry) GetPluginFor(category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType) (Plugin, error) {
+	return r.GetPluginForVersion(category, capabilityType, "")
+}
+
+// GetPluginForVersion retrieves the Plugin handling the specified category and type pair for the given version (or range of
+// versions, as supported by util.ParseVersionRange). An empty version is satisfied by any plugin supporting the pair.
+func (r *Registry) GetPluginForVersion(category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType, version string) (Plugin, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if types, ok := r.plugins[categoryKey(category)]; ok {
+		if candidates, ok := types[typeKey(capabilityType)]; ok && len(candidates) > 0 {
+			supported := make([]string, 0, len(candidates))
+			for _, p := range candidates {
+				typeInfo, _ := typeInfoFor(p, capabilityType)
+				ok, err := typeInfo.Supports(version)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					return p, nil
+				}
+				supported = append(supported, typeInfo.Versions...)
+			}
+			return nil, fmt.Errorf("couldn't find a plugin to handle version '%s' of capability with category '%s' and type '%s', supported versions: %s",
+				version, category, capabilityType, strings.Join(supported, ", "))
 		}
 	}
 	return nil, fmt.Errorf("couldn't find a plugin to handle capability with category '%s' and type '%s'", category, capabilityType)
 }
 
+// GetPluginForCapability retrieves the Plugin handling the category, type and version requested by the specified Capability
+func (r *Registry) GetPluginForCapability(capability *halkyon.Capability) (Plugin, error) {
+	return r.GetPluginForVersion(capability.Spec.Category, capability.Spec.Type, capability.Spec.Version)
+}
+
+// Plugins returns the Plugins registered in this Registry, each Plugin appearing only once even if it handles several types
+func (r *Registry) Plugins() []Plugin {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	seen := make(map[Plugin]bool, 7)
+	result := make([]Plugin, 0, 7)
+	for _, types := range r.plugins {
+		for _, candidates := range types {
+			for _, p := range candidates {
+				if !seen[p] {
+					seen[p] = true
+					result = append(result, p)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Subscribe registers the specified listener so that it gets notified of changes occurring in this Registry. Listeners are
+// called synchronously, outside of the Registry's lock, and therefore can safely call the Registry.
+func (r *Registry) Subscribe(listener RegistryListener) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+func (r *Registry) notify(events []RegistryEvent) {
+	r.mutex.RLock()
+	listeners := append([]RegistryListener{}, r.listeners...)
+	r.mutex.RUnlock()
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
 func categoryKey(category halkyon.CapabilityCategory) halkyon.CapabilityCategory {
 	return halkyon.CapabilityCategory(strings.ToLower(category.String()))
 }
@@ -35,84 +286,413 @@ func typeKey(capType halkyon.CapabilityType) halkyon.CapabilityType {
 	return halkyon.CapabilityType(strings.ToLower(capType.String()))
 }
 
-func register(p *PluginClient) {
-	category := p.GetCategory()
-	categoryKey := categoryKey(category)
-	if len(plugins) == 0 {
-		plugins = make(pluginsRegistry, 7)
+// typeInfoFor retrieves the TypeInfo the specified Plugin provides for the given CapabilityType
+func typeInfoFor(p Plugin, capType halkyon.CapabilityType) (TypeInfo, bool) {
+	key := typeKey(capType)
+	for _, typeInfo := range p.GetTypes() {
+		if typeKey(typeInfo.Type) == key {
+			return typeInfo, true
+		}
 	}
-	types, ok := plugins[categoryKey]
-	if !ok {
-		types = make(typeRegistry, 7)
-		plugins[categoryKey] = types
+	return TypeInfo{}, false
+}
+
+// Register registers the specified Plugin for each of the category/type pairs it supports. Several Plugins can handle the same
+// pair as long as they support different versions. Conflicts between Plugins supporting overlapping versions are resolved
+// according to the Registry's ConflictResolution: losing Plugins are shadowed for the pair and recorded in the associated
+// CapabilityInfo. An error is only returned when using the FailFast policy, in which case the Plugin is not registered at all.
+// The CapabilityInfo associated with each pair is then created or updated accordingly, outside of the Registry's lock, failures
+// to do so being logged.
+func (r *Registry) Register(p Plugin) error {
+	r.mutex.Lock()
+	if r.resolution.Policy == FailFast {
+		if err := r.checkConflicts(p, nil); err != nil {
+			r.mutex.Unlock()
+			return err
+		}
 	}
+	events, pairs := r.register(p)
+	r.mutex.Unlock()
+
+	r.publish(pairs)
+	r.notify(events)
+	return nil
+}
+
+// register registers the specified Plugin, resolving conflicts according to the Registry's ConflictResolution, and returns the
+// resulting events along with the pairs which CapabilityInfos need to be published. It must be called holding the Registry's
+// lock.
+func (r *Registry) register(p Plugin) ([]RegistryEvent, []capabilityPair) {
+	category := p.GetCategory()
+	categoryKey := categoryKey(category)
 	typeInfos := p.GetTypes()
+	events := make([]RegistryEvent, 0, len(typeInfos))
+	pairs := make([]capabilityPair, 0, len(typeInfos))
+
+	types := r.plugins.typesFor(categoryKey)
+	shadowedTypes := r.shadowed.typesFor(categoryKey)
 	for _, typeInfo := range typeInfos {
 		t := typeInfo.Type
 		typeKey := typeKey(t)
-		plug, ok := types[typeKey]
-		if ok {
-			p.log.Error(fmt.Errorf("a plugin named '%s' is already registered for '%s'/'%s' category/type pair", plug.Name(), category, t),
-				fmt.Sprintf("'%s' plugin will not be registered to provide capability '%s'/'%s'", p.Name(), category, t))
+		candidates := types[typeKey]
+		shadowed := shadowedTypes[typeKey]
+		if contains(candidates, p) || contains(shadowed, p) {
+			r.log.Info(fmt.Sprintf("'%s' plugin is already registered for '%s'/'%s' category/type pair", p.Name(), category, t))
+			continue
+		}
+
+		// resolve conflicts with registered plugins supporting overlapping versions
+		remaining, losers, wins := r.resolve(p, typeInfo, candidates)
+
+		// register plugin, the associated CapabilityInfo being published once the lock is released
+		types[typeKey] = remaining
+		shadowedTypes[typeKey] = append(shadowed[:len(shadowed):len(shadowed)], losers...)
+		pairs = append(pairs, capabilityPair{category: category, capType: t})
+		for _, loser := range losers {
+			events = append(events, RegistryEvent{Type: PluginShadowed, Plugin: loser, Category: category, CapType: t})
+			r.log.Info(fmt.Sprintf("'%s' plugin is shadowed for category '%s' / type '%s' pair according to '%s' policy", loser.Name(), category, t, r.resolution.Policy))
+		}
+		if wins {
+			events = append(events, RegistryEvent{Type: PluginRegistered, Plugin: p, Category: category, CapType: t})
+			r.log.Info(fmt.Sprintf("Registered plugin named '%s' for category '%s' / type '%s' pair", p.Name(), category, t))
+		}
+	}
+	return events, pairs
+}
+
+// resolve resolves the conflicts between the specified Plugin, supporting the given TypeInfo, and the candidates registered for
+// the same pair according to the Registry's ConflictResolution. It returns the resulting candidates, the Plugins losing the
+// conflicts, i.e. the specified Plugin itself if it doesn't win, and whether the specified Plugin wins.
+func (r *Registry) resolve(p Plugin, typeInfo TypeInfo, candidates []Plugin) (remaining []Plugin, losers []Plugin, wins bool) {
+	t := typeInfo.Type
+	remaining = make([]Plugin, 0, len(candidates)+1)
+	losers = make([]Plugin, 0, 1)
+	for _, plug := range candidates {
+		registered, _ := typeInfoFor(plug, t)
+		if !registered.overlaps(typeInfo) {
+			remaining = append(remaining, plug)
+			continue
+		}
+		if !r.resolution.wins(t, p, plug) {
+			return candidates, []Plugin{p}, false
+		}
+		losers = append(losers, plug)
+	}
+	return append(remaining, p), losers, true
+}
+
+// checkConflicts checks whether the specified Plugin conflicts with already registered ones, except for the replaced one if
+// any, for any of the types it supports
+func (r *Registry) checkConflicts(p Plugin, replaced Plugin) error {
+	category := p.GetCategory()
+	types := r.plugins[categoryKey(category)]
+	for _, typeInfo := range p.GetTypes() {
+		for _, plug := range types[typeKey(typeInfo.Type)] {
+			registered, _ := typeInfoFor(plug, typeInfo.Type)
+			if plug != p && plug != replaced && registered.overlaps(typeInfo) {
+				return fmt.Errorf("'%s' plugin conflicts with '%s' plugin already registered for '%s'/'%s' category/type pair with versions '%s'",
+					p.Name(), plug.Name(), category, typeInfo.Type, v1beta1.VersionsAsString(registered.Versions...))
+			}
+		}
+	}
+	return nil
+}
+
+// Unregister removes the specified Plugin from this Registry so that it doesn't handle any category/type pair anymore. Conflicts
+// are then resolved again for the Plugins shadowed for the pairs the unregistered Plugin handled, so that the ones it shadowed
+// handle these pairs in its place. The CapabilityInfos of pairs still handled by other Plugins are then updated accordingly,
+// outside of the Registry's lock, the other ones being left for PurgeCapabilityInfos to remove. Unregistering a Plugin doesn't
+// kill it.
+func (r *Registry) Unregister(p Plugin) {
+	r.mutex.Lock()
+	events, pairs, freed := r.unregister(p)
+	events = append(events, r.promote(freed)...)
+	r.mutex.Unlock()
+
+	r.publish(pairs)
+	r.notify(events)
+}
+
+// Replace atomically swaps the specified old Plugin with the new one, which is registered as Register would, so that lookups
+// never observe a state where neither is registered. Plugins shadowed for the pairs the old Plugin handled then compete with
+// the new one and the remaining candidates as they do when unregistering a Plugin. The CapabilityInfos of the affected pairs
+// are then published once, outside of the Registry's lock. As with Register, an error is only returned when using the FailFast
+// policy and the new Plugin conflicts with Plugins other than the old one, in which case the Registry is left untouched.
+// Replacing a Plugin doesn't kill it.
+func (r *Registry) Replace(old, p Plugin) error {
+	r.mutex.Lock()
+	if r.resolution.Policy == FailFast {
+		if err := r.checkConflicts(p, old); err != nil {
+			r.mutex.Unlock()
+			return err
+		}
+	}
+	events, pairs, freed := r.unregister(old)
+	registeredEvents, registeredPairs := r.register(p)
+	events = append(append(events, registeredEvents...), r.promote(freed)...)
+	r.mutex.Unlock()
+
+	r.publish(mergePairs(pairs, registeredPairs))
+	r.notify(events)
+	return nil
+}
+
+// unregister removes the specified Plugin from this Registry and returns the resulting events along with the pairs which
+// CapabilityInfos need to be published and the pairs the Plugin handled, i.e. for which it wasn't shadowed. It must be called
+// holding the Registry's lock.
+func (r *Registry) unregister(p Plugin) (events []RegistryEvent, pairs []capabilityPair, freed []capabilityPair) {
+	category := p.GetCategory()
+	categoryKey := categoryKey(category)
+	events = make([]RegistryEvent, 0, 7)
+	pairs = make([]capabilityPair, 0, 7)
+	freed = make([]capabilityPair, 0, 7)
+
+	types := r.plugins.typesFor(categoryKey)
+	shadowedTypes := r.shadowed.typesFor(categoryKey)
+	for _, typeInfo := range p.GetTypes() {
+		t := typeInfo.Type
+		typeKey := typeKey(t)
+		candidates, shadowed := types[typeKey], shadowedTypes[typeKey]
+		registered := contains(candidates, p)
+		if !registered && !contains(shadowed, p) {
+			continue
+		}
+		candidates, shadowed = without(candidates, p), without(shadowed, p)
+		if len(candidates) == 0 {
+			delete(types, typeKey)
+		} else {
+			types[typeKey] = candidates
+		}
+		if len(shadowed) == 0 {
+			delete(shadowedTypes, typeKey)
+		} else {
+			shadowedTypes[typeKey] = shadowed
+		}
+		pairs = append(pairs, capabilityPair{category: category, capType: t})
+		if registered {
+			freed = append(freed, capabilityPair{category: category, capType: t})
+			events = append(events, RegistryEvent{Type: PluginUnregistered, Plugin: p, Category: category, CapType: t})
+			r.log.Info(fmt.Sprintf("Unregistered plugin named '%s' for category '%s' / type '%s' pair", p.Name(), category, t))
+		}
+	}
+	return events, pairs, freed
+}
+
+// promote resolves conflicts again for the Plugins shadowed for the specified pairs, in the order they were shadowed, against
+// the Plugins currently handling these pairs so that Plugins which were shadowed by a Plugin that doesn't handle a pair anymore
+// can handle it, and returns the resulting events. The pairs' CapabilityInfos need to be published. It must be called holding
+// the Registry's lock.
+func (r *Registry) promote(pairs []capabilityPair) []RegistryEvent {
+	events := make([]RegistryEvent, 0, len(pairs))
+	for _, pair := range pairs {
+		types := r.plugins.typesFor(categoryKey(pair.category))
+		shadowedTypes := r.shadowed.typesFor(categoryKey(pair.category))
+		typeKey := typeKey(pair.capType)
+		candidates, shadowed := types[typeKey], shadowedTypes[typeKey]
+		if len(shadowed) == 0 {
 			continue
 		}
 
-		// create or update associated CapabilityInfo
-		capabilityName := fmt.Sprintf("%v-%v", categoryKey, typeKey)
-		capInfo := &v1beta1.CapabilityInfo{
-			ObjectMeta: v1.ObjectMeta{Name: capabilityName},
-			Spec: v1beta1.CapabilityInfoSpec{
-				Versions: v1beta1.VersionsAsString(typeInfo.Versions...),
-				Category: category.String(),
-				Type:     t.String(),
-			},
+		stillShadowed := make([]Plugin, 0, len(shadowed))
+		for _, p := range shadowed {
+			typeInfo, _ := typeInfoFor(p, pair.capType)
+			remaining, losers, wins := r.resolve(p, typeInfo, candidates)
+			if !wins {
+				stillShadowed = append(stillShadowed, p)
+				continue
+			}
+			candidates = remaining
+			stillShadowed = append(stillShadowed, losers...)
+			for _, loser := range losers {
+				events = append(events, RegistryEvent{Type: PluginShadowed, Plugin: loser, Category: pair.category, CapType: pair.capType})
+				r.log.Info(fmt.Sprintf("'%s' plugin is shadowed for category '%s' / type '%s' pair according to '%s' policy", loser.Name(), pair.category, pair.capType, r.resolution.Policy))
+			}
+			events = append(events, RegistryEvent{Type: PluginRegistered, Plugin: p, Category: pair.category, CapType: pair.capType})
+			r.log.Info(fmt.Sprintf("Registered previously shadowed plugin named '%s' for category '%s' / type '%s' pair", p.Name(), pair.category, pair.capType))
 		}
 
-		// check if the capability info already exist
-		ci, err := capInfoClient.Get(capabilityName, v1.GetOptions{})
-		if err == nil {
-			// if it exists, update it with potentially new information
-			capInfo.ResourceVersion = ci.ResourceVersion
-			_, err = capInfoClient.Update(capInfo)
+		if len(candidates) > 0 {
+			types[typeKey] = candidates
+		}
+		if len(stillShadowed) == 0 {
+			delete(shadowedTypes, typeKey)
 		} else {
-			// if not create it
-			if errors.IsNotFound(err) {
-				_, err = capInfoClient.Create(capInfo)
+			shadowedTypes[typeKey] = stillShadowed
+		}
+	}
+	return events
+}
+
+// Shadowed returns the Plugins which are shadowed for the specified category/type pair because they lost a conflict
+func (r *Registry) Shadowed(category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType) []Plugin {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]Plugin{}, r.shadowed[categoryKey(category)][typeKey(capabilityType)]...)
+}
+
+func (p pluginsRegistry) typesFor(category halkyon.CapabilityCategory) typeRegistry {
+	types, ok := p[category]
+	if !ok {
+		types = make(typeRegistry, 7)
+		p[category] = types
+	}
+	return types
+}
+
+func contains(plugins []Plugin, p Plugin) bool {
+	for _, plug := range plugins {
+		if plug == p {
+			return true
+		}
+	}
+	return false
+}
+
+func without(plugins []Plugin, p Plugin) []Plugin {
+	result := make([]Plugin, 0, len(plugins))
+	for _, plug := range plugins {
+		if plug != p {
+			result = append(result, plug)
+		}
+	}
+	return result
+}
+
+// capabilityPair identifies a category/type pair which CapabilityInfo needs to be published
+type capabilityPair struct {
+	category halkyon.CapabilityCategory
+	capType  halkyon.CapabilityType
+}
+
+// mergePairs appends the specified other pairs which are not already part of the given pairs to them
+func mergePairs(pairs []capabilityPair, others []capabilityPair) []capabilityPair {
+	for _, other := range others {
+		found := false
+		for _, pair := range pairs {
+			if categoryKey(pair.category) == categoryKey(other.category) && typeKey(pair.capType) == typeKey(other.capType) {
+				found = true
+				break
 			}
 		}
+		if !found {
+			pairs = append(pairs, other)
+		}
+	}
+	return pairs
+}
 
-		// if an error occurred at any time, log it and ignore the capability
+// publish creates or updates the CapabilityInfos associated with the specified pairs, or with all the pairs handled by this
+// Registry if some CapabilityInfos couldn't be published while it was offline. It must be
+// called without holding the Registry's lock so that lookups are not blocked by the API calls. Nothing is published if this
+// Registry is offline.
+func (r *Registry) publish(pairs []capabilityPair) {
+	capInfoClient, stale := r.capabilityInfos()
+	if capInfoClient == nil {
+		return
+	}
+	if stale {
+		pairs = r.pairs()
+	}
+	r.publishWith(capInfoClient, pairs)
+}
+
+// publishWith publishes the CapabilityInfos associated with the specified pairs using the given client. Publications are
+// serialized and each one reflects the state of the Registry at the time it's performed so that the last published
+// CapabilityInfo is always up to date. Pairs which are not handled by any Plugin anymore are left for PurgeCapabilityInfos to
+// remove.
+func (r *Registry) publishWith(capInfoClient CapabilityInfoClient, pairs []capabilityPair) {
+	if len(pairs) == 0 {
+		return
+	}
+	r.publishing.Lock()
+	defer r.publishing.Unlock()
+	for _, pair := range pairs {
+		r.mutex.RLock()
+		ownerID := r.ownerID
+		plugins := append([]Plugin{}, r.plugins[categoryKey(pair.category)][typeKey(pair.capType)]...)
+		shadowed := append([]Plugin{}, r.shadowed[categoryKey(pair.category)][typeKey(pair.capType)]...)
+		r.mutex.RUnlock()
+		if len(plugins) == 0 {
+			continue
+		}
+		published, err := publishCapabilityInfo(capInfoClient, ownerID, pair.category, pair.capType, plugins, shadowed)
 		if err != nil {
-			p.log.Error(err, fmt.Sprintf("couldn't create or update capabilityinfo named '%s', associated capability will be ignored", capabilityName))
+			r.log.Error(err, fmt.Sprintf("couldn't create or update capabilityinfo for '%s'/'%s'", pair.category, pair.capType))
 			continue
 		}
+		r.reportShadowed(published, pair.capType, shadowed)
+	}
+}
 
-		// if everything went well, register plugin
-		types[typeKey] = p
-		p.log.Info(fmt.Sprintf("Registered plugin named '%s' for category '%s' / type '%s' pair", p.name, category, t))
+// pairs returns the category/type pairs currently handled by this Registry
+func (r *Registry) pairs() []capabilityPair {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	pairs := make([]capabilityPair, 0, 7)
+	for _, types := range r.plugins {
+		for capType, plugins := range types {
+			if len(plugins) > 0 {
+				// use the category and type as declared by the plugin rather than their keys
+				typeInfo, _ := typeInfoFor(plugins[0], capType)
+				pairs = append(pairs, capabilityPair{category: plugins[0].GetCategory(), capType: typeInfo.Type})
+			}
+		}
 	}
+	return pairs
 }
 
-func PurgeCapabilityInfos(log logr.Logger) (purgedCount int, err error) {
-	existing, err := capInfoClient.List(v1.ListOptions{})
+// publishCapabilityInfo creates or updates the CapabilityInfo associated with the specified category/type pair using the given
+// client so that it reflects the specified Plugins handling it as well as the shadowed ones
+func publishCapabilityInfo(capInfoClient CapabilityInfoClient, ownerID string, category halkyon.CapabilityCategory, t halkyon.CapabilityType, plugins []Plugin, shadowed []Plugin) (*v1beta1.CapabilityInfo, error) {
+	versions := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		typeInfo, _ := typeInfoFor(p, t)
+		versions = append(versions, typeInfo.Versions...)
+	}
+	capabilityName := fmt.Sprintf("%v-%v", categoryKey(category), typeKey(t))
+	capInfo := &v1beta1.CapabilityInfo{
+		ObjectMeta: v1.ObjectMeta{Name: capabilityName, Annotations: map[string]string{}, Labels: map[string]string{}},
+		Spec: v1beta1.CapabilityInfoSpec{
+			Versions: v1beta1.VersionsAsString(versions...),
+			Category: category.String(),
+			Type:     t.String(),
+		},
+	}
+	// record which operator instance published the CapabilityInfo so that it only purges its own
+	if len(ownerID) > 0 {
+		capInfo.Labels[ownerLabel(ownerID)] = "true"
+	}
+	// publish the parameters the registered plugins accept for tooling
+	parameters, err := parametersAnnotation(t, plugins...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	msgs := make([]string, 0, len(existing.Items))
-	for _, info := range existing.Items {
-		_, err := GetPluginFor(halkyon.CapabilityCategory(info.Spec.Category), halkyon.CapabilityType(info.Spec.Type))
-		if err != nil {
-			// plugin for info doesn't exist, so we should remove it
-			if err := capInfoClient.Delete(info.Name, v1.NewDeleteOptions(0)); err != nil {
-				msgs = append(msgs, fmt.Sprintf("%s: %s", info.Name, err.Error()))
+	if len(parameters) > 0 {
+		capInfo.Annotations[ParametersAnnotation] = parameters
+	}
+	// record shadowed plugins so that administrators can see them
+	shadowedPlugins, err := shadowedAnnotation(t, shadowed...)
+	if err != nil {
+		return nil, err
+	}
+	if len(shadowedPlugins) > 0 {
+		capInfo.Annotations[ShadowedPluginsAnnotation] = shadowedPlugins
+	}
+	// check if the capability info already exist
+	ci, err := capInfoClient.Get(capabilityName, v1.GetOptions{})
+	if err == nil {
+		// if it exists, update it with potentially new information, keeping the ownership of other operator instances
+		capInfo.ResourceVersion = ci.ResourceVersion
+		for label, value := range ci.Labels {
+			if strings.HasPrefix(label, OwnerLabelPrefix) {
+				capInfo.Labels[label] = value
 			}
-			log.Info(fmt.Sprintf("purged %s CapabilityInfo", info.Name))
-			purgedCount++
 		}
+		return capInfoClient.Update(capInfo)
 	}
-	if len(msgs) > 0 {
-		err = fmt.Errorf("couldn't delete CapabilityInfo(s): %s", strings.Join(msgs, ", "))
+	// if not create it
+	if errors.IsNotFound(err) {
+		return capInfoClient.Create(capInfo)
 	}
-	return
+	return nil, err
 }
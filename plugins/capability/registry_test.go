@@ -0,0 +1,434 @@
+package capability
+
+import (
+	"fmt"
+	capinfo "halkyon.io/api/capability-info/v1beta1"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubPlugin is a Plugin which only provides its metadata
+type stubPlugin struct {
+	name     string
+	category halkyon.CapabilityCategory
+	types    []TypeInfo
+}
+
+var _ Plugin = &stubPlugin{}
+
+func (s *stubPlugin) Name() string                                                     { return s.name }
+func (s *stubPlugin) GetCategory() halkyon.CapabilityCategory                          { return s.category }
+func (s *stubPlugin) GetTypes() []TypeInfo                                             { return s.types }
+func (s *stubPlugin) ReadyFor(owner *halkyon.Capability) []framework.DependentResource { return nil }
+func (s *stubPlugin) Kill()                                                            {}
+func (s *stubPlugin) CheckValidity(in *halkyon.Capability) error                       { return nil }
+func (s *stubPlugin) OnDelete(owner *halkyon.Capability) error                         { return nil }
+func (s *stubPlugin) OnFinalize(owner *halkyon.Capability) error                       { return nil }
+func (s *stubPlugin) GetOutputs(owner *halkyon.Capability) ([]v1beta1.NameValuePair, error) {
+	return nil, nil
+}
+
+func newStubPlugin(name string, category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType, versions ...string) *stubPlugin {
+	return &stubPlugin{name: name, category: category, types: []TypeInfo{{Type: capabilityType, Versions: versions}}}
+}
+
+// fakeCapabilityInfos is an in-memory CapabilityInfoClient. If gate is set, Get waits for it to be closed after signaling on
+// entered, if set, so that tests can check what happens while CapabilityInfos are being published. Deleting the CapabilityInfos
+// recorded in deleteErrors fails with the associated error.
+type fakeCapabilityInfos struct {
+	mutex        sync.Mutex
+	infos        map[string]capinfo.CapabilityInfo
+	version      int
+	entered      chan struct{}
+	gate         chan struct{}
+	deleteErrors map[string]error
+}
+
+var _ CapabilityInfoClient = &fakeCapabilityInfos{}
+
+func newFakeCapabilityInfos(infos ...capinfo.CapabilityInfo) *fakeCapabilityInfos {
+	f := &fakeCapabilityInfos{infos: make(map[string]capinfo.CapabilityInfo, len(infos))}
+	for _, info := range infos {
+		f.infos[info.Name] = info
+	}
+	return f
+}
+
+func (f *fakeCapabilityInfos) notFound(name string) error {
+	return errors.NewNotFound(schema.GroupResource{Group: "halkyon.io", Resource: "capabilityinfos"}, name)
+}
+
+func (f *fakeCapabilityInfos) Get(name string, options v1.GetOptions) (*capinfo.CapabilityInfo, error) {
+	if f.gate != nil {
+		if f.entered != nil {
+			f.entered <- struct{}{}
+		}
+		<-f.gate
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	info, ok := f.infos[name]
+	if !ok {
+		return nil, f.notFound(name)
+	}
+	return &info, nil
+}
+
+func (f *fakeCapabilityInfos) List(opts v1.ListOptions) (*capinfo.CapabilityInfoList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	list := &capinfo.CapabilityInfoList{}
+	for _, info := range f.infos {
+		if selector.Matches(labels.Set(info.Labels)) {
+			list.Items = append(list.Items, info)
+		}
+	}
+	return list, nil
+}
+
+func (f *fakeCapabilityInfos) Create(info *capinfo.CapabilityInfo) (*capinfo.CapabilityInfo, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.infos[info.Name]; ok {
+		return nil, errors.NewAlreadyExists(schema.GroupResource{Group: "halkyon.io", Resource: "capabilityinfos"}, info.Name)
+	}
+	return f.store(info), nil
+}
+
+func (f *fakeCapabilityInfos) Update(info *capinfo.CapabilityInfo) (*capinfo.CapabilityInfo, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	existing, ok := f.infos[info.Name]
+	if !ok {
+		return nil, f.notFound(info.Name)
+	}
+	if existing.ResourceVersion != info.ResourceVersion {
+		return nil, errors.NewConflict(schema.GroupResource{Group: "halkyon.io", Resource: "capabilityinfos"}, info.Name, fmt.Errorf("stale resource version"))
+	}
+	return f.store(info), nil
+}
+
+func (f *fakeCapabilityInfos) store(info *capinfo.CapabilityInfo) *capinfo.CapabilityInfo {
+	f.version++
+	stored := *info
+	stored.ResourceVersion = strconv.Itoa(f.version)
+	f.infos[info.Name] = stored
+	return &stored
+}
+
+func (f *fakeCapabilityInfos) Delete(name string, options *v1.DeleteOptions) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.infos[name]; !ok {
+		return f.notFound(name)
+	}
+	if err, ok := f.deleteErrors[name]; ok {
+		return err
+	}
+	delete(f.infos, name)
+	return nil
+}
+
+func (f *fakeCapabilityInfos) get(name string) (capinfo.CapabilityInfo, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	info, ok := f.infos[name]
+	return info, ok
+}
+
+func TestRegistryLookupsAreNotBlockedByPublication(t *testing.T) {
+	infos := newFakeCapabilityInfos()
+	infos.entered = make(chan struct{})
+	infos.gate = make(chan struct{})
+	registry := NewRegistry(log.Log)
+	registry.SetCapabilityInfoClient(infos)
+
+	p := newStubPlugin("postgres-plugin", "database", "postgres", "11")
+	registered := make(chan error, 1)
+	go func() { registered <- registry.Register(p) }()
+	<-infos.entered
+
+	// the CapabilityInfo is being published: lookups should neither block nor miss the plugin
+	found := make(chan Plugin, 1)
+	go func() {
+		plugin, _ := registry.GetPluginFor("database", "postgres")
+		found <- plugin
+	}()
+	select {
+	case plugin := <-found:
+		if plugin != p {
+			t.Errorf("expected registered plugin to be found while publishing, got %v", plugin)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup blocked while publishing CapabilityInfo")
+	}
+
+	close(infos.gate)
+	if err := <-registered; err != nil {
+		t.Fatal(err)
+	}
+	info, ok := infos.get("database-postgres")
+	if !ok || info.Spec.Versions != "11" {
+		t.Errorf("expected CapabilityInfo to be published with version 11, got %v", info)
+	}
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	infos := newFakeCapabilityInfos()
+	registry := NewRegistry(log.Log)
+	registry.SetCapabilityInfoClient(infos)
+
+	const workers = 8
+	plugins := make([]*stubPlugin, workers)
+	for i := range plugins {
+		plugins[i] = newStubPlugin(fmt.Sprintf("plugin-%d", i), "database", "postgres", strconv.Itoa(i))
+	}
+	errs := make(chan error, workers*2)
+	var wg sync.WaitGroup
+	for i := range plugins {
+		wg.Add(2)
+		p, version := plugins[i], strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := registry.Register(p); err != nil {
+					errs <- err
+					return
+				}
+				if found, err := registry.GetPluginForVersion("database", "postgres", version); err != nil || found != p {
+					errs <- fmt.Errorf("expected %s to be found right after being registered, got %v (%v)", p.Name(), found, err)
+					return
+				}
+				registry.Unregister(p)
+			}
+			// leave the plugin registered so that the final state is known
+			if err := registry.Register(p); err != nil {
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, _ = registry.GetPluginFor("database", "postgres")
+				_ = registry.Plugins()
+				_ = registry.Shadowed("database", "postgres")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if registered := registry.Plugins(); len(registered) != workers {
+		t.Errorf("expected %d plugins to be registered, got %d", workers, len(registered))
+	}
+	info, ok := infos.get("database-postgres")
+	if !ok {
+		t.Fatal("expected CapabilityInfo to be published")
+	}
+	versions := strings.Split(info.Spec.Versions, ",")
+	if len(versions) != workers {
+		t.Errorf("expected published CapabilityInfo to reflect the %d registered plugins, got versions %v", workers, versions)
+	}
+}
+
+func TestRegistryResolvesCapabilityInfoClientOnUse(t *testing.T) {
+	logger := newRecordingLogger()
+	registry := NewRegistry(logger)
+	helper := &framework.K8SHelper{}
+	registry.SetHelper(helper)
+
+	// registry is offline as long as the helper isn't initialized, which is only logged once
+	for _, p := range []Plugin{newStubPlugin("postgres-plugin", "database", "postgres", "11"), newStubPlugin("redis-plugin", "cache", "redis", "5")} {
+		if err := registry.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client, _ := registry.capabilityInfos(); client != nil {
+		t.Errorf("expected registry to be offline without configuration, got %v", client)
+	}
+	offline := 0
+	for _, entry := range logger.recorded.all() {
+		if strings.Contains(entry.msg, "no Kubernetes configuration available") {
+			offline++
+		}
+	}
+	if offline != 1 {
+		t.Errorf("expected offline registry to be logged once, got %d time(s)", offline)
+	}
+
+	// client is created once the helper is initialized, reporting that CapabilityInfos need to be published
+	helper.Config = &rest.Config{Host: "http://127.0.0.1:1"}
+	client, stale := registry.capabilityInfos()
+	if client == nil || !stale {
+		t.Fatalf("expected client to be created once configuration is available and CapabilityInfos to be stale, got %v (stale: %v)", client, stale)
+	}
+	if client, stale := registry.capabilityInfos(); client == nil || stale {
+		t.Errorf("expected client to be reused and CapabilityInfos to be up to date, got %v (stale: %v)", client, stale)
+	}
+
+	// going offline explicitly takes precedence
+	registry.SetOffline(true)
+	if client, _ := registry.capabilityInfos(); client != nil {
+		t.Errorf("expected offline registry not to provide a client, got %v", client)
+	}
+}
+
+func TestRegistryPublishesCapabilityInfosRegisteredWhileOffline(t *testing.T) {
+	tests := []struct {
+		name   string
+		online func(registry *Registry) error
+	}{
+		{name: "register", online: func(registry *Registry) error {
+			return registry.Register(newStubPlugin("mysql-plugin", "database", "mysql", "8"))
+		}},
+		{name: "purge", online: func(registry *Registry) error {
+			_, err := registry.PurgeCapabilityInfosWith(log.Log, PurgeOptions{})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(log.Log)
+			registry.SetHelper(&framework.K8SHelper{})
+			if err := registry.Register(newStubPlugin("postgres-plugin", "database", "postgres", "11")); err != nil {
+				t.Fatal(err)
+			}
+
+			infos := newFakeCapabilityInfos()
+			registry.SetCapabilityInfoClient(infos)
+			if err := tt.online(registry); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := infos.get("database-postgres"); !ok {
+				t.Error("expected CapabilityInfo of plugin registered while offline to be published")
+			}
+		})
+	}
+}
+
+func TestRegistryReplace(t *testing.T) {
+	infos := newFakeCapabilityInfos()
+	registry := NewRegistry(log.Log)
+	registry.SetCapabilityInfoClient(infos)
+	old := newStubPlugin("postgres-v1", "database", "postgres", "10", "11")
+	if err := registry.Register(old); err != nil {
+		t.Fatal(err)
+	}
+	events := make([]RegistryEvent, 0, 2)
+	registry.Subscribe(func(event RegistryEvent) {
+		events = append(events, event)
+	})
+	published := infos.version
+
+	replacement := newStubPlugin("postgres-v2", "database", "postgres", "10", "11", "12")
+	if err := registry.Replace(old, replacement); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := registry.GetPluginForVersion("database", "postgres", "12"); err != nil || p != replacement {
+		t.Errorf("expected replacement plugin to be registered, got %v (%v)", p, err)
+	}
+	if plugins := registry.Plugins(); len(plugins) != 1 {
+		t.Errorf("expected replaced plugin to be unregistered, got %v", names(plugins))
+	}
+	expected := []RegistryEvent{
+		{Type: PluginUnregistered, Plugin: old, Category: "database", CapType: "postgres"},
+		{Type: PluginRegistered, Plugin: replacement, Category: "database", CapType: "postgres"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+	if count := infos.version - published; count != 1 {
+		t.Errorf("expected CapabilityInfo to be published once, got %d publications", count)
+	}
+	if info, _ := infos.get("database-postgres"); info.Spec.Versions != capinfo.VersionsAsString("10", "11", "12") {
+		t.Errorf("expected CapabilityInfo to list the versions of the replacement plugin, got %s", info.Spec.Versions)
+	}
+}
+
+func TestRegistryReplaceIsAtomic(t *testing.T) {
+	registry := NewRegistry(log.Log)
+	registry.SetOffline(true)
+	current := Plugin(newStubPlugin("postgres-0", "database", "postgres", "11"))
+	if err := registry.Register(current); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	failures := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				close(failures)
+				return
+			default:
+			}
+			if _, err := registry.GetPluginFor("database", "postgres"); err != nil {
+				failures <- err
+				close(failures)
+				return
+			}
+		}
+	}()
+	for i := 1; i <= 100; i++ {
+		replacement := newStubPlugin(fmt.Sprintf("postgres-%d", i), "database", "postgres", "11")
+		if err := registry.Replace(current, replacement); err != nil {
+			t.Fatal(err)
+		}
+		current = replacement
+	}
+	close(done)
+	if err := <-failures; err != nil {
+		t.Errorf("expected a plugin to always be registered while replacing it, got: %v", err)
+	}
+}
+
+func TestRegistryReplaceFailFast(t *testing.T) {
+	registry := NewRegistry(log.Log)
+	registry.SetOffline(true)
+	if err := registry.SetConflictResolution(ConflictResolution{Policy: FailFast}); err != nil {
+		t.Fatal(err)
+	}
+	old := newStubPlugin("postgres-v1", "database", "postgres", "10", "11")
+	other := newStubPlugin("postgres-13", "database", "postgres", "13")
+	for _, p := range []Plugin{old, other} {
+		if err := registry.Register(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the replacement only conflicts with the plugin it replaces
+	replacement := newStubPlugin("postgres-v2", "database", "postgres", "11", "12")
+	if err := registry.Replace(old, replacement); err != nil {
+		t.Fatalf("expected replacing a plugin with an overlapping version to succeed: %v", err)
+	}
+
+	// conflicts with other plugins leave the registry untouched
+	conflicting := newStubPlugin("postgres-v3", "database", "postgres", "12", "13")
+	if err := registry.Replace(replacement, conflicting); err == nil {
+		t.Fatal("expected replacing a plugin with one conflicting with other plugins to fail")
+	}
+	if p, err := registry.GetPluginForVersion("database", "postgres", "12"); err != nil || p != replacement {
+		t.Errorf("expected replaced plugin to still be registered, got %v (%v)", p, err)
+	}
+}
@@ -7,6 +7,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// PluginRequest carries all the request-scoped state of a call from the host to a plugin: the owner on behalf of which the call
+// is made, the dependent it targets, if any, and its argument, if any. Neither the host nor the plugin keep any other per-request
+// state, which allows concurrent calls to be made through a single plugin process.
 type PluginRequest struct {
 	Owner  framework.SerializableResource
 	Target schema.GroupVersionKind
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/hashicorp/go-hclog"
 	halkyon "halkyon.io/api/capability/v1beta1"
+	"halkyon.io/api/v1beta1"
 	framework "halkyon.io/operator-framework"
 	"reflect"
+	"sync"
 )
 
 // PluginResource gathers behavior that plugin implementors are expected to provide to the plugins architecture
@@ -26,10 +28,11 @@ type PluginResource interface {
 }
 
 type SimplePluginResourceStem struct {
-	ct     []TypeInfo
-	cc     halkyon.CapabilityCategory
-	name   string
-	Logger hclog.Logger
+	ct         []TypeInfo
+	cc         halkyon.CapabilityCategory
+	name       string
+	Logger     hclog.Logger
+	KubeClient KubeClient
 }
 
 func NewSimplePluginResourceStem(cat halkyon.CapabilityCategory, typ TypeInfo) SimplePluginResourceStem {
@@ -47,6 +50,10 @@ func (p *SimplePluginResourceStem) SetLogger(logger hclog.Logger) {
 	p.Logger = logger
 }
 
+func (p *SimplePluginResourceStem) SetKubeClient(client KubeClient) {
+	p.KubeClient = client
+}
+
 func (p SimplePluginResourceStem) GetPrefixedValidationMessage(msg string) string {
 	return fmt.Sprintf("%s: %s", p.name, msg)
 }
@@ -55,9 +62,27 @@ type NeedsLogging interface {
 	SetLogger(logger hclog.Logger)
 }
 
+// DeletionAware is an optional interface PluginResources can implement to be notified when a Capability they handle is deleted,
+// e.g. to drop a database they created
+type DeletionAware interface {
+	// OnDelete is called with the last known state of the specified owner when it's being deleted, while its dependents still
+	// exist. Returning an error prevents the owner from being deleted, OnDelete being called again later.
+	OnDelete(owner framework.SerializableResource) error
+}
+
+// FinalizationAware is an optional interface PluginResources can implement to perform final clean-up operations when a
+// Capability they handle is deleted, e.g. to revoke external credentials
+type FinalizationAware interface {
+	// OnFinalize is called with the last known state of the specified owner once OnDelete, if implemented, succeeded, right
+	// before the owner's finalizer is removed. Returning an error prevents the owner from being deleted, OnFinalize being called
+	// again later.
+	OnFinalize(owner framework.SerializableResource) error
+}
+
 type QueryingSimplePluginResourceStem struct {
 	SimplePluginResourceStem
 	resolver func(logger hclog.Logger) TypeInfo
+	resolved sync.Once
 }
 
 func NewQueryingSimplePluginResourceStem(cat halkyon.CapabilityCategory, typeInfoResolver func(logger hclog.Logger) TypeInfo) QueryingSimplePluginResourceStem {
@@ -67,14 +92,22 @@ func NewQueryingSimplePluginResourceStem(cat halkyon.CapabilityCategory, typeInf
 	}
 }
 
+// GetSupportedTypes resolves the supported TypeInfo on first call, concurrent calls waiting for the resolution to complete. A
+// zero value, without resolver, doesn't support any type.
 func (p *QueryingSimplePluginResourceStem) GetSupportedTypes() []TypeInfo {
-	if len(p.ct) == 0 {
-		p.ct = []TypeInfo{p.resolver(p.Logger)}
-	}
+	p.resolved.Do(func() {
+		if p.resolver != nil {
+			p.ct = []TypeInfo{p.resolver(p.Logger)}
+		}
+	})
 	return p.ct
 }
 
 var _ PluginResource = &AggregatePluginResource{}
+var _ NeedsKubeClient = &AggregatePluginResource{}
+var _ DeletionAware = &AggregatePluginResource{}
+var _ FinalizationAware = &AggregatePluginResource{}
+var _ OutputsProvider = &AggregatePluginResource{}
 
 type AggregatePluginResource struct {
 	category        halkyon.CapabilityCategory
@@ -117,8 +150,54 @@ func (a AggregatePluginResource) GetSupportedTypes() []TypeInfo {
 }
 
 func (a AggregatePluginResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
-	capType := typeKey(owner.(*halkyon.Capability).Spec.Type)
-	return a.pluginResources[capType].GetDependentResourcesWith(owner)
+	return a.resourceFor(owner).GetDependentResourcesWith(owner)
+}
+
+// OnDelete forwards to the PluginResource handling the specified owner if it is DeletionAware
+func (a AggregatePluginResource) OnDelete(owner framework.SerializableResource) error {
+	if aware, ok := a.resourceFor(owner).(DeletionAware); ok {
+		return aware.OnDelete(owner)
+	}
+	return nil
+}
+
+// OnFinalize forwards to the PluginResource handling the specified owner if it is FinalizationAware
+func (a AggregatePluginResource) OnFinalize(owner framework.SerializableResource) error {
+	if aware, ok := a.resourceFor(owner).(FinalizationAware); ok {
+		return aware.OnFinalize(owner)
+	}
+	return nil
+}
+
+// GetOutputs forwards to the PluginResource handling the specified owner if it is an OutputsProvider
+func (a AggregatePluginResource) GetOutputs(owner framework.SerializableResource) []v1beta1.NameValuePair {
+	if provider, ok := a.resourceFor(owner).(OutputsProvider); ok {
+		return provider.GetOutputs(owner)
+	}
+	return nil
+}
+
+func (a AggregatePluginResource) resourceFor(owner framework.SerializableResource) PluginResource {
+	return a.pluginResources[typeKey(owner.(*halkyon.Capability).Spec.Type)]
+}
+
+// handlerFor returns the PluginResource actually handling the specified owner: the aggregated PluginResource supporting its type
+// if the specified PluginResource is an AggregatePluginResource, which implements all optional hooks by forwarding them, the
+// specified PluginResource otherwise
+func handlerFor(resource PluginResource, owner framework.SerializableResource) PluginResource {
+	if aggregate, ok := resource.(AggregatePluginResource); ok {
+		return aggregate.resourceFor(owner)
+	}
+	return resource
+}
+
+// SetKubeClient passes the specified KubeClient to the aggregated PluginResources needing it
+func (a AggregatePluginResource) SetKubeClient(client KubeClient) {
+	for _, resource := range a.pluginResources {
+		if needsClient, ok := resource.(NeedsKubeClient); ok {
+			needsClient.SetKubeClient(client)
+		}
+	}
 }
 
 func (a AggregatePluginResource) CheckValidity(owner framework.SerializableResource) []string {
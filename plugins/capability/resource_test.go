@@ -0,0 +1,36 @@
+package capability
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestQueryingSimplePluginResourceStemResolvesOnce(t *testing.T) {
+	var resolutions int32
+	stem := NewQueryingSimplePluginResourceStem("database", func(logger hclog.Logger) TypeInfo {
+		atomic.AddInt32(&resolutions, 1)
+		return postgres
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if types := stem.GetSupportedTypes(); len(types) != 1 || types[0].Type != postgres.Type {
+				t.Errorf("expected resolved postgres type, got %v", types)
+			}
+		}()
+	}
+	wg.Wait()
+	if resolutions != 1 {
+		t.Errorf("expected types to be resolved once, got %d resolutions", resolutions)
+	}
+
+	var zero QueryingSimplePluginResourceStem
+	if types := zero.GetSupportedTypes(); len(types) != 0 {
+		t.Errorf("expected zero value not to support any type, got %v", types)
+	}
+}
@@ -23,19 +23,26 @@ type PluginServer interface {
 	Update(req PluginRequest, res *UpdateResponse) error
 	GetConfig(req PluginRequest, res *framework.DependentResourceConfig) error
 	CheckValidity(req PluginRequest, res *[]string) error
+	ConnectKubeProxy(brokerID uint32, res *bool) error
+	OnDelete(req PluginRequest, res *bool) error
+	OnFinalize(req PluginRequest, res *bool) error
+	GetOutputs(req PluginRequest, res *[]v1beta1.NameValuePair) error
 }
 
 type PluginServerImpl struct {
 	capability PluginResource
 	logger     hclog.Logger
+	broker     *plugin.MuxBroker
 }
 
 func (p PluginServerImpl) CheckValidity(req PluginRequest, res *[]string) error {
+	p.traceCall("CheckValidity", req)
 	*res = p.capability.CheckValidity(req.Owner)
 	return nil
 }
 
 func (p PluginServerImpl) GetConfig(req PluginRequest, res *framework.DependentResourceConfig) error {
+	p.traceCall("GetConfig", req)
 	resource := p.dependentResourceFor(req)
 	*res = resource.GetConfig()
 	return nil
@@ -43,13 +50,64 @@ func (p PluginServerImpl) GetConfig(req PluginRequest, res *framework.DependentR
 
 var _ PluginServer = &PluginServerImpl{}
 
+// ConnectKubeProxy connects to the KubeProxyServer the host serves with the specified broker ID and passes the resulting
+// KubeClient to the plugin's resources needing it
+func (p PluginServerImpl) ConnectKubeProxy(brokerID uint32, res *bool) error {
+	conn, err := p.broker.Dial(brokerID)
+	if err != nil {
+		return err
+	}
+	if needsClient, ok := p.capability.(NeedsKubeClient); ok {
+		needsClient.SetKubeClient(newKubeProxyClient(conn))
+	}
+	*res = true
+	return nil
+}
+
+// OnDelete calls the plugin's OnDelete hook, if any, for the requested owner, setting the response to whether a hook was called,
+// i.e. whether the PluginResource handling the owner is DeletionAware
+func (p PluginServerImpl) OnDelete(req PluginRequest, res *bool) error {
+	logger := p.traceCall("OnDelete", req)
+	aware, ok := handlerFor(p.capability, req.Owner).(DeletionAware)
+	*res = ok
+	if !ok {
+		return nil
+	}
+	if err := aware.OnDelete(req.Owner); err != nil {
+		logger.Error("deletion hook failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// OnFinalize calls the plugin's OnFinalize hook, if any, for the requested owner, setting the response to whether a hook was
+// called, i.e. whether the PluginResource handling the owner is FinalizationAware
+func (p PluginServerImpl) OnFinalize(req PluginRequest, res *bool) error {
+	logger := p.traceCall("OnFinalize", req)
+	aware, ok := handlerFor(p.capability, req.Owner).(FinalizationAware)
+	*res = ok
+	if !ok {
+		return nil
+	}
+	if err := aware.OnFinalize(req.Owner); err != nil {
+		logger.Error("finalization hook failed", "error", err)
+		return err
+	}
+	return nil
+}
+
+// GetOutputs retrieves the outputs the plugin provides for the requested owner, if any
+func (p PluginServerImpl) GetOutputs(req PluginRequest, res *[]v1beta1.NameValuePair) error {
+	p.traceCall("GetOutputs", req)
+	if provider, ok := p.capability.(OutputsProvider); ok {
+		*res = provider.GetOutputs(req.Owner)
+	}
+	return nil
+}
+
 func StartPluginServerFor(resources ...PluginResource) {
 	pluginName := GetPluginExecutableName()
-	logger := hclog.New(&hclog.LoggerOptions{
-		Output: hclog.DefaultOutput,
-		Level:  hclog.Trace,
-		Name:   pluginName,
-	})
+	logger := newPluginLogger(pluginName)
 	p, err := NewAggregatePluginResource(logger, resources...)
 	if err != nil {
 		panic(err)
@@ -62,9 +120,11 @@ func StartPluginServerFor(resources ...PluginResource) {
 }
 
 func (p PluginServerImpl) Build(req PluginRequest, res *BuildResponse) error {
+	logger := p.traceCall("Build", req)
 	resource := p.dependentResourceFor(req)
 	build, err := resource.Build(false)
 	if err != nil {
+		logger.Error("couldn't build dependent", "type", req.Target.String(), "error", err)
 		return err
 	}
 	res.Built, err = framework.CreateUnstructuredObject(build, req.Target)
@@ -77,6 +137,7 @@ func (p PluginServerImpl) GetCategory(_ PluginRequest, res *halkyon.CapabilityCa
 }
 
 func (p PluginServerImpl) GetDependentResourceTypes(req PluginRequest, res *[]schema.GroupVersionKind) error {
+	p.traceCall("GetDependentResourceTypes", req)
 	dependents := p.capability.GetDependentResourcesWith(req.Owner)
 	*res = make([]schema.GroupVersionKind, 0, len(dependents))
 	for _, dependent := range dependents {
@@ -92,22 +153,26 @@ func (p PluginServerImpl) GetTypes(req PluginRequest, res *[]TypeInfo) error {
 
 // Currently, plugins cannot process the error and must rely on default error handling
 func (p PluginServerImpl) GetCondition(req PluginRequest, res *v1beta1.DependentCondition) error {
+	p.traceCall("GetCondition", req)
 	resource := p.dependentResourceFor(req)
 	*res = *resource.GetCondition(requestedArg(resource, req), nil)
 	return nil
 }
 
 func (p PluginServerImpl) Name(req PluginRequest, res *string) error {
+	p.traceCall("Name", req)
 	resource := p.dependentResourceFor(req)
 	*res = resource.Name()
 	return nil
 }
 
 func (p PluginServerImpl) Update(req PluginRequest, res *UpdateResponse) error {
+	logger := p.traceCall("Update", req)
 	resource := p.dependentResourceFor(req)
 	toUpdate := requestedArg(resource, req)
 	update, toUpdate, err := resource.Update(toUpdate)
 	if err != nil {
+		logger.Error("couldn't update dependent", "type", req.Target.String(), "error", err)
 		return err
 	}
 	updateAsUnstructured, err := framework.CreateUnstructuredObject(toUpdate, req.Target)
@@ -119,6 +184,13 @@ func (p PluginServerImpl) Update(req PluginRequest, res *UpdateResponse) error {
 	return err
 }
 
+// traceCall logs the specified call and returns a logger decorated with information about it
+func (p PluginServerImpl) traceCall(method string, req PluginRequest) hclog.Logger {
+	logger := requestLogger(p.logger, method, req)
+	logger.Trace("handling call")
+	return logger
+}
+
 func (p PluginServerImpl) dependentResourceFor(req PluginRequest) framework.DependentResource {
 	dependents := p.capability.GetDependentResourcesWith(req.Owner)
 	for _, dependent := range dependents {
@@ -0,0 +1,70 @@
+package capability
+
+import (
+	"github.com/hashicorp/go-hclog"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"testing"
+)
+
+// hookedResource is a PluginResource implementing the deletion and finalization hooks, recording the owners they're called for
+type hookedResource struct {
+	validatingResource
+	deleted   []string
+	finalized []string
+}
+
+func (h *hookedResource) OnDelete(owner framework.SerializableResource) error {
+	h.deleted = append(h.deleted, owner.GetName())
+	return nil
+}
+
+func (h *hookedResource) OnFinalize(owner framework.SerializableResource) error {
+	h.finalized = append(h.finalized, owner.GetName())
+	return nil
+}
+
+func TestPluginServerReportsWhetherHooksAreCalled(t *testing.T) {
+	hooked := &hookedResource{validatingResource: validatingResource{SimplePluginResourceStem: NewSimplePluginResourceStem("database", redis)}}
+	plain := &validatingResource{SimplePluginResourceStem: NewSimplePluginResourceStem("database", postgres)}
+	aggregate, err := NewAggregatePluginResource(hclog.NewNullLogger(), hooked, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := PluginServerImpl{capability: aggregate, logger: hclog.NewNullLogger()}
+
+	tests := []struct {
+		name     string
+		capType  halkyon.CapabilityType
+		expected bool
+	}{
+		{"hooks implemented", "redis", true},
+		{"hooks not implemented", "postgres", false},
+		{"unsupported type", "mysql", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := PluginRequest{Owner: newCapability(tt.name, "database", tt.capType, "5")}
+			var deleted, finalized bool
+			if err := server.OnDelete(req, &deleted); err != nil {
+				t.Fatal(err)
+			}
+			if err := server.OnFinalize(req, &finalized); err != nil {
+				t.Fatal(err)
+			}
+			if deleted != tt.expected || finalized != tt.expected {
+				t.Errorf("expected hooks to be reported as called: %v, got OnDelete: %v, OnFinalize: %v", tt.expected, deleted, finalized)
+			}
+		})
+	}
+	if len(hooked.deleted) != 1 || len(hooked.finalized) != 1 || hooked.deleted[0] != "hooks implemented" {
+		t.Errorf("expected hooks to only be called for the capability their resource handles, got %v and %v", hooked.deleted, hooked.finalized)
+	}
+
+	// non-aggregated resources report whether they implement the hooks themselves
+	server.capability = hooked
+	var called bool
+	if err := server.OnDelete(PluginRequest{Owner: newCapability("cache", "database", "redis", "5")}, &called); err != nil || !called {
+		t.Errorf("expected OnDelete hook to be called, got %v (%v)", called, err)
+	}
+}
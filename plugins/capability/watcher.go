@@ -0,0 +1,264 @@
+package capability
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 10 * time.Second
+	defaultDrainTimeout  = 30 * time.Second
+)
+
+// PluginWatcher watches a plugins directory, starting and registering newly added plugin binaries, replacing upgraded ones and
+// unregistering removed ones, so that plugins can be added or upgraded without restarting the operator. The directory is polled
+// and files are only considered once they haven't changed between two polls, to avoid starting partially copied binaries, except
+// for the files present when the directory is first scanned which are started right away.
+// PluginWatcher implements manager.Runnable so that it can be added to a controller-runtime Manager.
+type PluginWatcher struct {
+	// Interval is the delay between two polls of the watched directory, defaults to 10 seconds
+	Interval time.Duration
+	// DrainTimeout is the maximum duration to wait for the in-flight calls of a replaced or removed plugin to complete before
+	// killing it, defaults to 30 seconds
+	DrainTimeout time.Duration
+
+	dir     string
+	log     logr.Logger
+	config  PluginConfig
+	mutex   sync.Mutex
+	plugins map[string]*watchedPlugin
+	pending map[string]fileState
+	// synced records whether the watched directory was already scanned
+	synced  bool
+	requeue chan event.GenericEvent
+	// start starts the plugin binary which path is given, startPlugin unless replaced in tests
+	start func(path string, log logr.Logger, config PluginConfig) (*PluginClient, error)
+}
+
+var _ manager.Runnable = &PluginWatcher{}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+type watchedPlugin struct {
+	plugin *PluginClient
+	state  fileState
+}
+
+// NewPluginWatcher creates a PluginWatcher for the specified directory, plugins being started and registered using the given
+// PluginConfig
+func NewPluginWatcher(dir string, log logr.Logger, config PluginConfig) *PluginWatcher {
+	return &PluginWatcher{
+		Interval:     defaultWatchInterval,
+		DrainTimeout: defaultDrainTimeout,
+		dir:          dir,
+		log:          log,
+		config:       config,
+		plugins:      make(map[string]*watchedPlugin, 7),
+		pending:      make(map[string]fileState, 7),
+		start:        startPlugin,
+	}
+}
+
+// RequeueSource returns a source.Source emitting a GenericEvent for each Capability affected by a plugin being added, replaced or
+// removed so that the Capability controller can requeue them. It needs to be called before the watcher is started to be
+// effective. Capabilities are listed using the Helper of the watcher's PluginConfig, which should therefore be the K8SHelper of
+// the Capability controller, see framework.GenericReconciler.GetHelper.
+func (w *PluginWatcher) RequeueSource() source.Source {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.requeue == nil {
+		w.requeue = make(chan event.GenericEvent, 100)
+	}
+	return &source.Channel{Source: w.requeue}
+}
+
+// Start polls the watched directory until the specified channel is closed, at which point the watched plugins are killed
+func (w *PluginWatcher) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		w.Sync(stop)
+		select {
+		case <-stop:
+			w.killAll()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync scans the watched directory once, handling the plugins which were added, changed or removed since the previous scan. The
+// specified channel is used to stop sending requeue events if the watcher is stopped.
+func (w *PluginWatcher) Sync(stop <-chan struct{}) {
+	files, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		w.log.Error(err, fmt.Sprintf("couldn't read plugins directory '%s'", w.dir))
+		return
+	}
+
+	// capabilities are requeued once the lock is released so that listing them and sending events doesn't block the watcher
+	w.mutex.Lock()
+	affected := w.sync(files)
+	requeue := w.requeue
+	w.mutex.Unlock()
+
+	if len(affected) > 0 {
+		w.requeueFor(requeue, stop, affected...)
+	}
+}
+
+// sync handles the plugins which were added, changed or removed according to the specified files, returning the plugins which
+// capabilities need to be requeued. It must be called holding the watcher's lock.
+func (w *PluginWatcher) sync(files []os.FileInfo) []Plugin {
+	// binaries present when the directory is first scanned are deemed complete
+	initial := !w.synced
+	w.synced = true
+	affected := make([]Plugin, 0, 7)
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(w.dir, file.Name())
+		present[path] = true
+		state := fileState{modTime: file.ModTime(), size: file.Size()}
+		watched, known := w.plugins[path]
+		if known && watched.state == state {
+			delete(w.pending, path)
+			continue
+		}
+		// wait for the file to be stable before (re)starting the plugin
+		if previous, ok := w.pending[path]; !initial && (!ok || previous != state) {
+			w.pending[path] = state
+			continue
+		}
+		delete(w.pending, path)
+		if known {
+			affected = append(affected, w.replace(path, watched, state)...)
+		} else {
+			affected = append(affected, w.add(path, state)...)
+		}
+	}
+
+	for path, watched := range w.plugins {
+		if !present[path] {
+			affected = append(affected, w.remove(path, watched)...)
+		}
+	}
+	for path := range w.pending {
+		if !present[path] {
+			delete(w.pending, path)
+		}
+	}
+	return affected
+}
+
+// add starts and registers the plugin which path is specified, returning it if successful
+func (w *PluginWatcher) add(path string, state fileState) []Plugin {
+	p, err := w.start(path, w.log, w.config)
+	if err != nil {
+		w.log.Error(err, fmt.Sprintf("couldn't start plugin '%s'", path))
+		return nil
+	}
+	if err := w.config.registry().Register(p); err != nil {
+		w.log.Error(err, fmt.Sprintf("couldn't register plugin '%s'", path))
+		p.Kill()
+		return nil
+	}
+	w.plugins[path] = &watchedPlugin{plugin: p, state: state}
+	w.log.Info(fmt.Sprintf("added plugin '%s'", path))
+	return []Plugin{p}
+}
+
+// replace starts the new version of a plugin before atomically swapping it with the old one in the registry, the old one being
+// killed once its in-flight calls are completed. Both versions are returned if successful.
+func (w *PluginWatcher) replace(path string, old *watchedPlugin, state fileState) []Plugin {
+	p, err := w.start(path, w.log, w.config)
+	if err != nil {
+		w.log.Error(err, fmt.Sprintf("couldn't start new version of plugin '%s', keeping the current one", path))
+		return nil
+	}
+	if err := w.config.registry().Replace(old.plugin, p); err != nil {
+		w.log.Error(err, fmt.Sprintf("couldn't register new version of plugin '%s', keeping the current one", path))
+		p.Kill()
+		return nil
+	}
+	w.plugins[path] = &watchedPlugin{plugin: p, state: state}
+	go old.plugin.drainAndKill(w.DrainTimeout)
+	w.log.Info(fmt.Sprintf("replaced plugin '%s'", path))
+	return []Plugin{old.plugin, p}
+}
+
+// remove unregisters the specified plugin, which is killed once its in-flight calls are completed, and returns it
+func (w *PluginWatcher) remove(path string, old *watchedPlugin) []Plugin {
+	w.config.registry().Unregister(old.plugin)
+	delete(w.plugins, path)
+	go old.plugin.drainAndKill(w.DrainTimeout)
+	w.log.Info(fmt.Sprintf("removed plugin '%s'", path))
+	return []Plugin{old.plugin}
+}
+
+func (w *PluginWatcher) killAll() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for path, watched := range w.plugins {
+		w.config.registry().Unregister(watched.plugin)
+		watched.plugin.Kill()
+		delete(w.plugins, path)
+	}
+}
+
+// requeueFor emits a GenericEvent on the specified requeue channel, if a RequeueSource was requested, for each Capability
+// handled by the specified plugins. It must be called without holding the watcher's lock since it can block.
+func (w *PluginWatcher) requeueFor(requeue chan<- event.GenericEvent, stop <-chan struct{}, plugins ...Plugin) {
+	if requeue == nil {
+		return
+	}
+	c := w.config.helper().Client
+	if c == nil {
+		w.log.Info("Kubernetes client isn't initialized, couldn't requeue capabilities")
+		return
+	}
+	capabilities := &halkyon.CapabilityList{}
+	if err := c.List(context.Background(), capabilities); err != nil {
+		w.log.Error(err, "couldn't list capabilities to requeue")
+		return
+	}
+	for i := range capabilities.Items {
+		capability := &capabilities.Items[i]
+		if !handles(capability, plugins...) {
+			continue
+		}
+		select {
+		case requeue <- event.GenericEvent{Meta: capability, Object: capability}:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func handles(capability *halkyon.Capability, plugins ...Plugin) bool {
+	for _, p := range plugins {
+		if !categoryKey(p.GetCategory()).Equals(categoryKey(capability.Spec.Category)) {
+			continue
+		}
+		if _, ok := typeInfoFor(p, capability.Spec.Type); ok {
+			return true
+		}
+	}
+	return false
+}
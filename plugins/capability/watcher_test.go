@@ -0,0 +1,328 @@
+package capability
+
+import (
+	"github.com/go-logr/logr"
+	halkyon "halkyon.io/api/capability/v1beta1"
+	framework "halkyon.io/operator-framework"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"testing"
+	"time"
+)
+
+var redis = TypeInfo{Type: "redis", Versions: []string{"5"}}
+
+func newCapability(name string, category halkyon.CapabilityCategory, capabilityType halkyon.CapabilityType, version string) *halkyon.Capability {
+	c := &halkyon.Capability{Spec: halkyon.CapabilitySpec{Category: category, Type: capabilityType, Version: version}}
+	c.Name = name
+	c.Namespace = "test"
+	return c
+}
+
+// newWatcher creates a PluginWatcher for a new temporary directory, starting in-process plugins supporting the postgres type
+// and using an offline Registry and a Helper with a fake client knowing about the specified Capabilities
+func newWatcher(t *testing.T, capabilities ...runtime.Object) (*PluginWatcher, *[]string) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := halkyon.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(log.Log)
+	registry.SetOffline(true)
+	helper := &framework.K8SHelper{Client: fake.NewFakeClientWithScheme(s, capabilities...), Scheme: s}
+
+	w := NewPluginWatcher(dir, log.Log, PluginConfig{Registry: registry, Helper: helper})
+	started := &[]string{}
+	w.start = func(path string, log logr.Logger, config PluginConfig) (*PluginClient, error) {
+		*started = append(*started, path)
+		p, err := NewInProcessPlugin(filepath.Base(path), log, config, &validatingResource{SimplePluginResourceStem: NewSimplePluginResourceStem("database", postgres)})
+		if err != nil {
+			return nil, err
+		}
+		return p.(*PluginClient), nil
+	}
+	return w, started
+}
+
+func writePlugin(t *testing.T, w *PluginWatcher, name, content string) string {
+	path := filepath.Join(w.dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// requeued returns the names of the capabilities for which a requeue event was emitted since the last call
+func requeued(w *PluginWatcher) []string {
+	names := []string{}
+	for {
+		select {
+		case e := <-w.requeue:
+			names = append(names, e.Meta.GetName())
+		default:
+			return names
+		}
+	}
+}
+
+func TestPluginWatcher(t *testing.T) {
+	w, started := newWatcher(t, newCapability("db", "database", "postgres", "11"), newCapability("cache", "database", "redis", "5"))
+	defer os.RemoveAll(w.dir)
+	w.RequeueSource()
+	stop := make(chan struct{})
+	defer close(stop)
+	registry := w.config.registry()
+
+	// plugins added once the directory was scanned are only started once their file is stable
+	w.Sync(stop)
+	path := writePlugin(t, w, "db-plugin", "v1")
+	writePlugin(t, w, ".hidden", "ignored")
+	w.Sync(stop)
+	if len(*started) != 0 {
+		t.Fatalf("expected plugin not to be started before its file is stable, got %v", *started)
+	}
+	w.Sync(stop)
+	if len(*started) != 1 || (*started)[0] != path {
+		t.Fatalf("expected '%s' plugin to be started, got %v", path, *started)
+	}
+	first, err := registry.GetPluginFor("database", "postgres")
+	if err != nil {
+		t.Fatalf("expected added plugin to be registered: %v", err)
+	}
+	if names := requeued(w); len(names) != 1 || names[0] != "db" {
+		t.Errorf("expected only 'db' capability to be requeued, got %v", names)
+	}
+
+	// unchanged plugins are left alone
+	w.Sync(stop)
+	if len(*started) != 1 {
+		t.Errorf("expected unchanged plugin not to be restarted, got %v", *started)
+	}
+
+	// changed plugins are replaced and the old version killed
+	writePlugin(t, w, "db-plugin", "version 2")
+	w.Sync(stop)
+	w.Sync(stop)
+	if len(*started) != 2 {
+		t.Fatalf("expected changed plugin to be restarted, got %v", *started)
+	}
+	second, err := registry.GetPluginFor("database", "postgres")
+	if err != nil || second == first {
+		t.Fatalf("expected new version of plugin to be registered instead of %v, got %v (%v)", first, second, err)
+	}
+	if names := requeued(w); len(names) != 1 || names[0] != "db" {
+		t.Errorf("expected only 'db' capability to be requeued, got %v", names)
+	}
+	waitUntilKilled(t, first.(*PluginClient))
+
+	// removed plugins are unregistered and killed
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	w.Sync(stop)
+	if p, err := registry.GetPluginFor("database", "postgres"); err == nil {
+		t.Errorf("expected removed plugin to be unregistered, got %v", p)
+	}
+	if names := requeued(w); len(names) != 1 || names[0] != "db" {
+		t.Errorf("expected only 'db' capability to be requeued, got %v", names)
+	}
+	waitUntilKilled(t, second.(*PluginClient))
+}
+
+func TestPluginWatcherStartsExistingPluginsRightAway(t *testing.T) {
+	w, started := newWatcher(t, newCapability("db", "database", "postgres", "11"))
+	defer os.RemoveAll(w.dir)
+	w.RequeueSource()
+	stop := make(chan struct{})
+	defer close(stop)
+
+	path := writePlugin(t, w, "db-plugin", "v1")
+	w.Sync(stop)
+	if len(*started) != 1 || (*started)[0] != path {
+		t.Fatalf("expected plugin present at startup to be started on first scan, got %v", *started)
+	}
+	if _, err := w.config.registry().GetPluginFor("database", "postgres"); err != nil {
+		t.Errorf("expected plugin present at startup to be registered: %v", err)
+	}
+	if names := requeued(w); len(names) != 1 || names[0] != "db" {
+		t.Errorf("expected only 'db' capability to be requeued, got %v", names)
+	}
+	w.killAll()
+}
+
+func TestPluginWatcherRequeuesWithoutHoldingLock(t *testing.T) {
+	w, _ := newWatcher(t, newCapability("db", "database", "postgres", "11"))
+	defer os.RemoveAll(w.dir)
+	// nobody consumes requeue events so that sending them blocks
+	w.requeue = make(chan event.GenericEvent)
+	stop := make(chan struct{})
+
+	writePlugin(t, w, "db-plugin", "v1")
+	synced := make(chan struct{})
+	go func() {
+		w.Sync(stop)
+		close(synced)
+	}()
+	// the plugin is registered before capabilities are requeued
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := w.config.registry().GetPluginFor("database", "postgres"); err != nil; _, err = w.config.registry().GetPluginFor("database", "postgres") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected plugin to be registered: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		w.RequeueSource()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Error("expected watcher not to be locked while requeueing capabilities")
+	}
+	if e := <-w.requeue; e.Meta.GetName() != "db" {
+		t.Errorf("expected 'db' capability to be requeued, got '%s'", e.Meta.GetName())
+	}
+	<-synced
+	close(stop)
+	w.killAll()
+}
+
+func TestPluginWatcherWithoutClient(t *testing.T) {
+	w, started := newWatcher(t)
+	defer os.RemoveAll(w.dir)
+	w.config.Helper = &framework.K8SHelper{}
+	w.RequeueSource()
+	stop := make(chan struct{})
+	defer close(stop)
+
+	writePlugin(t, w, "db-plugin", "v1")
+	w.Sync(stop)
+	w.Sync(stop)
+	if len(*started) != 1 {
+		t.Fatalf("expected plugin to be started, got %v", *started)
+	}
+	if names := requeued(w); len(names) != 0 {
+		t.Errorf("expected no capability to be requeued without a client, got %v", names)
+	}
+	w.killAll()
+}
+
+// waitUntilKilled waits for the specified in-process plugin to be killed, which happens asynchronously once it's drained
+func waitUntilKilled(t *testing.T, p *PluginClient) {
+	deadline := time.Now().Add(5 * time.Second)
+	capability := newCapability("db", "database", "postgres", "11")
+	capability.Spec.Parameters = parameters("DB_NAME", "db")
+	for time.Now().Before(deadline) {
+		if err := p.CheckValidity(capability); err == rpc.ErrShutdown {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected '%s' plugin to be killed", p.Name())
+}
+
+// blockingResource is a PluginResource which validation blocks until released
+type blockingResource struct {
+	SimplePluginResourceStem
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingResource) GetDependentResourcesWith(owner framework.SerializableResource) []framework.DependentResource {
+	return nil
+}
+
+func (b *blockingResource) CheckValidity(owner framework.SerializableResource) []string {
+	b.entered <- struct{}{}
+	<-b.release
+	return nil
+}
+
+func newBlockingPlugin(t *testing.T) (*PluginClient, *blockingResource) {
+	resource := &blockingResource{
+		SimplePluginResourceStem: NewSimplePluginResourceStem("cache", redis),
+		entered:                  make(chan struct{}, 1),
+		release:                  make(chan struct{}),
+	}
+	p, err := NewInProcessPlugin("blocking", log.Log, PluginConfig{}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*PluginClient), resource
+}
+
+func TestDrainAndKillWaitsForCallsInFlight(t *testing.T) {
+	p, resource := newBlockingPlugin(t)
+	capability := newCapability("cache", "cache", "redis", "5")
+	inFlight := make(chan error, 1)
+	go func() { inFlight <- p.CheckValidity(capability) }()
+	<-resource.entered
+
+	killed := make(chan struct{})
+	go func() {
+		p.drainAndKill(time.Minute)
+		close(killed)
+	}()
+	select {
+	case <-killed:
+		t.Fatal("expected plugin not to be killed while a call is in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(resource.release)
+	if err := <-inFlight; err != nil {
+		t.Errorf("expected call in flight to complete, got: %v", err)
+	}
+	select {
+	case <-killed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected plugin to be killed once drained")
+	}
+	if err := p.CheckValidity(capability); err == nil {
+		t.Error("expected calls to fail once the plugin is killed")
+	}
+}
+
+func TestDrainAndKillTimesOut(t *testing.T) {
+	p, resource := newBlockingPlugin(t)
+	defer close(resource.release)
+	capability := newCapability("cache", "cache", "redis", "5")
+	inFlight := make(chan error, 1)
+	go func() { inFlight <- p.CheckValidity(capability) }()
+	<-resource.entered
+
+	killed := make(chan struct{})
+	go func() {
+		p.drainAndKill(20 * time.Millisecond)
+		close(killed)
+	}()
+	select {
+	case <-killed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected plugin to be killed once the drain timeout expired")
+	}
+	select {
+	case err := <-inFlight:
+		if err == nil {
+			t.Error("expected call in flight to fail once the plugin is killed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected call in flight to be interrupted once the plugin is killed")
+	}
+}
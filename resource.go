@@ -1,6 +1,7 @@
 package framework
 
 import (
+	"context"
 	"halkyon.io/api/v1beta1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -41,6 +42,20 @@ type Resource interface {
 	InitDependentResources() ([]DependentResource, error)
 }
 
+// ContextAwareResource is an optional interface Resources can implement to receive the context of the reconcile request they
+// are processed for, so that the calls they make to the cluster can be cancelled, time-boxed or traced. The framework uses
+// these methods instead of their context-less counterparts when they are available. Note that BaseResource already provides
+// ComputeStatusWithContext so Resources embedding it only need to implement CreateOrUpdateWithContext, typically by calling
+// BaseResource.CreateOrUpdateDependentsWithContext, but then also need to override ComputeStatusWithContext if they override
+// ComputeStatus.
+type ContextAwareResource interface {
+	Resource
+	// ComputeStatusWithContext is the context-aware version of ComputeStatus
+	ComputeStatusWithContext(ctx context.Context) (needsUpdate bool)
+	// CreateOrUpdateWithContext is the context-aware version of CreateOrUpdate
+	CreateOrUpdateWithContext(ctx context.Context) error
+}
+
 // SerializableResource is the interface that resources that need to be transmitted to plugins need to implement. In particular,
 // since such resources go through the Unstructured mechanism, we need to be able to know their GVK at all times.
 type SerializableResource interface {
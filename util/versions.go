@@ -0,0 +1,357 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version represents a semantic version. Missing minor and patch components are considered to be 0.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease string
+}
+
+// ParseVersion parses the specified string as a semantic version. A leading 'v' is accepted, as are versions missing their minor
+// and/or patch components (e.g. "11" is parsed as 11.0.0). Build metadata is ignored.
+func ParseVersion(version string) (Version, error) {
+	v, components, err := parseVersion(version)
+	if err != nil {
+		return Version{}, err
+	}
+	if components <= 0 {
+		return Version{}, fmt.Errorf("'%s' is a version range, not a version", version)
+	}
+	return v, nil
+}
+
+// MustParseVersion is similar to ParseVersion except that errors will panic instead
+func MustParseVersion(version string) Version {
+	v, err := ParseVersion(version)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Compare returns -1, 0 or 1 depending on whether this Version is lower, equal to or greater than the specified one
+func (v Version) Compare(other Version) int {
+	if c := compareInts(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	// a pre-release version has a lower precedence than the associated normal version
+	switch {
+	case v.PreRelease == other.PreRelease:
+		return 0
+	case len(v.PreRelease) == 0:
+		return 1
+	case len(other.PreRelease) == 0:
+		return -1
+	default:
+		return strings.Compare(v.PreRelease, other.PreRelease)
+	}
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		s += "-" + v.PreRelease
+	}
+	return s
+}
+
+// next returns the lowest version greater than all the versions sharing the specified number of components with this Version,
+// e.g. the next version of 11.2.0 with 1 component is 12.0.0
+func (v Version) next(components int) Version {
+	switch components {
+	case 1:
+		return Version{Major: v.Major + 1}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseVersion parses the specified version, also returning the number of components which were specified: 3 for complete
+// versions, the opposite of the number of specified components for partial versions (e.g. -2 for "11.2" or "11.2.x") and 0 for
+// wildcards matching all versions
+func parseVersion(version string) (Version, int, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	v := Version{}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.PreRelease = s[i+1:]
+		s = s[:i]
+	}
+	if len(s) == 0 {
+		return v, 0, fmt.Errorf("'%s' is not a valid version", version)
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, 0, fmt.Errorf("'%s' is not a valid version: too many components", version)
+	}
+	components := 0
+	values := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			if i < len(parts)-1 || len(v.PreRelease) > 0 {
+				return v, 0, fmt.Errorf("'%s' is not a valid version: wildcards must be last", version)
+			}
+			return v, -i, nil
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("'%s' is not a valid version: '%s' is not a valid component", version, part)
+		}
+		*values[i] = n
+		components++
+	}
+	if components < 3 {
+		if len(v.PreRelease) > 0 {
+			return v, 0, fmt.Errorf("'%s' is not a valid version: pre-release versions must be complete", version)
+		}
+		// partial versions represent ranges, e.g. "11" represents all 11.x.y versions
+		return v, -components, nil
+	}
+	return v, components, nil
+}
+
+// VersionRange represents a set of versions, as a union of version intervals
+type VersionRange struct {
+	intervals   []versionInterval
+	description string
+}
+
+// versionInterval represents an interval of versions, nil bounds meaning that the interval is unbounded on that side
+type versionInterval struct {
+	min          *Version
+	minInclusive bool
+	max          *Version
+	maxInclusive bool
+}
+
+// AllVersions is the VersionRange containing all versions
+var AllVersions = VersionRange{intervals: []versionInterval{{}}, description: "*"}
+
+// ParseVersionRange parses the specified string as a VersionRange. The following syntax is supported:
+//   - an empty string, "*" or "x" matches all versions,
+//   - a complete version (e.g. "11.2.3") matches exactly that version,
+//   - a partial or wildcard version (e.g. "11", "11.x" or "11.2.*") matches all versions sharing the specified components,
+//   - comparisons (">", ">=", "<", "<=", "=") with a complete or partial version,
+//   - tilde ranges (e.g. "~11.2" matching versions from 11.2.0 up to, excluding, 11.3.0),
+//   - caret ranges (e.g. "^11.2" matching versions from 11.2.0 up to, excluding, 12.0.0),
+//   - comma- or space-separated constraints which all need to be satisfied (e.g. ">=10, <12"),
+//   - "||"-separated alternatives, at least one of which needs to be satisfied (e.g. "9.6 || >=11").
+func ParseVersionRange(versionRange string) (VersionRange, error) {
+	description := strings.TrimSpace(versionRange)
+	if len(description) == 0 || description == "*" || description == "x" || description == "X" {
+		return AllVersions, nil
+	}
+	r := VersionRange{description: description}
+	for _, alternative := range strings.Split(description, "||") {
+		constraints := strings.FieldsFunc(alternative, func(c rune) bool { return c == ',' || c == ' ' })
+		if len(constraints) == 0 {
+			return VersionRange{}, fmt.Errorf("'%s' is not a valid version range: empty alternative", versionRange)
+		}
+		interval := versionInterval{}
+		for _, constraint := range constraints {
+			c, err := parseConstraint(constraint)
+			if err != nil {
+				return VersionRange{}, fmt.Errorf("'%s' is not a valid version range: %v", versionRange, err)
+			}
+			interval = interval.intersect(c)
+		}
+		if !interval.isEmpty() {
+			r.intervals = append(r.intervals, interval)
+		}
+	}
+	return r, nil
+}
+
+// MustParseVersionRange is similar to ParseVersionRange except that errors will panic instead
+func MustParseVersionRange(versionRange string) VersionRange {
+	r, err := ParseVersionRange(versionRange)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Contains checks whether the specified Version is part of this VersionRange
+func (r VersionRange) Contains(version Version) bool {
+	for _, interval := range r.intervals {
+		if interval.contains(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// Overlaps checks whether at least one version is part of both this VersionRange and the specified one
+func (r VersionRange) Overlaps(other VersionRange) bool {
+	for _, interval := range r.intervals {
+		for _, otherInterval := range other.intervals {
+			if !interval.intersect(otherInterval).isEmpty() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Highest returns the highest bound of this VersionRange, i.e. the highest of its intervals' upper bounds or, for intervals
+// without upper bound, lower bounds. This provides a way to order ranges, typically to find which one supports the most recent
+// versions. The boolean result is false if the range has no bound at all (e.g. AllVersions).
+func (r VersionRange) Highest() (Version, bool) {
+	var highest *Version
+	for _, interval := range r.intervals {
+		bound := interval.max
+		if bound == nil {
+			bound = interval.min
+		}
+		if bound != nil && (highest == nil || bound.Compare(*highest) > 0) {
+			highest = bound
+		}
+	}
+	if highest == nil {
+		return Version{}, false
+	}
+	return *highest, true
+}
+
+func (r VersionRange) String() string {
+	return r.description
+}
+
+func parseConstraint(constraint string) (versionInterval, error) {
+	operator := ""
+	for _, op := range []string{">=", "<=", "==", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(constraint, op) {
+			operator = op
+			break
+		}
+	}
+	v, components, err := parseVersion(strings.TrimPrefix(constraint, operator))
+	if err != nil {
+		return versionInterval{}, err
+	}
+	partial := components <= 0
+	if components < 0 {
+		components = -components
+	}
+	// the interval of versions sharing the specified components
+	lowest := v
+	var upper Version
+	if partial {
+		if components == 0 {
+			return versionInterval{}, nil
+		}
+		upper = v.next(components)
+	} else {
+		upper = v
+	}
+
+	switch operator {
+	case "", "=", "==":
+		if partial {
+			return versionInterval{min: &lowest, minInclusive: true, max: &upper}, nil
+		}
+		return versionInterval{min: &lowest, minInclusive: true, max: &upper, maxInclusive: true}, nil
+	case ">":
+		if partial {
+			return versionInterval{min: &upper, minInclusive: true}, nil
+		}
+		return versionInterval{min: &lowest}, nil
+	case ">=":
+		return versionInterval{min: &lowest, minInclusive: true}, nil
+	case "<":
+		return versionInterval{max: &lowest}, nil
+	case "<=":
+		if partial {
+			return versionInterval{max: &upper}, nil
+		}
+		return versionInterval{max: &upper, maxInclusive: true}, nil
+	case "~":
+		if components == 1 {
+			upper = v.next(1)
+		} else {
+			upper = v.next(2)
+		}
+		return versionInterval{min: &lowest, minInclusive: true, max: &upper}, nil
+	default: // "^"
+		switch {
+		case v.Major > 0 || components == 1:
+			upper = v.next(1)
+		case v.Minor > 0 || components == 2:
+			upper = v.next(2)
+		default:
+			upper = v.next(3)
+		}
+		return versionInterval{min: &lowest, minInclusive: true, max: &upper}, nil
+	}
+}
+
+func (i versionInterval) contains(v Version) bool {
+	if i.min != nil {
+		c := v.Compare(*i.min)
+		if c < 0 || (c == 0 && !i.minInclusive) {
+			return false
+		}
+	}
+	if i.max != nil {
+		c := v.Compare(*i.max)
+		if c > 0 || (c == 0 && !i.maxInclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i versionInterval) intersect(other versionInterval) versionInterval {
+	result := i
+	if other.min != nil {
+		if result.min == nil {
+			result.min, result.minInclusive = other.min, other.minInclusive
+		} else if c := other.min.Compare(*result.min); c > 0 || (c == 0 && !other.minInclusive) {
+			result.min, result.minInclusive = other.min, other.minInclusive
+		}
+	}
+	if other.max != nil {
+		if result.max == nil {
+			result.max, result.maxInclusive = other.max, other.maxInclusive
+		} else if c := other.max.Compare(*result.max); c < 0 || (c == 0 && !other.maxInclusive) {
+			result.max, result.maxInclusive = other.max, other.maxInclusive
+		}
+	}
+	return result
+}
+
+func (i versionInterval) isEmpty() bool {
+	if i.min == nil || i.max == nil {
+		return false
+	}
+	c := i.min.Compare(*i.max)
+	return c > 0 || (c == 0 && !(i.minInclusive && i.maxInclusive))
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestVersionRangeContains(t *testing.T) {
+	var tests = []struct {
+		testName     string
+		versionRange string
+		version      string
+		want         bool
+	}{
+		{"all versions", "", "11.2.3", true},
+		{"wildcard", "*", "9.6.0", true},
+		{"exact version", "11.2.3", "11.2.3", true},
+		{"exact version mismatch", "11.2.3", "11.2.4", false},
+		{"partial major", "11", "11.9.1", true},
+		{"partial major excludes next major", "11", "12.0.0", false},
+		{"partial minor", "9.6", "9.6.12", true},
+		{"wildcard minor", "11.x", "11.4.0", true},
+		{"wildcard patch", "11.2.*", "11.3.0", false},
+		{"greater or equal", ">=10", "10.0.0", true},
+		{"greater than partial", ">10", "10.9.9", false},
+		{"greater than partial next major", ">10", "11.0.0", true},
+		{"lower than", "<11", "10.99.0", true},
+		{"lower or equal partial", "<=11", "11.5.0", true},
+		{"conjunction", ">=10, <12", "11.0.0", true},
+		{"conjunction upper bound", ">=10,<12", "12.0.0", false},
+		{"space-separated conjunction", ">=10 <12", "9.0.0", false},
+		{"alternatives", "9.6 || >=11", "11.1.0", true},
+		{"alternatives gap", "9.6 || >=11", "10.0.0", false},
+		{"tilde", "~11.2", "11.2.9", true},
+		{"tilde upper bound", "~11.2.3", "11.3.0", false},
+		{"caret", "^11.2", "11.9.0", true},
+		{"caret upper bound", "^11.2", "12.0.0", false},
+		{"caret zero major", "^0.2.3", "0.3.0", false},
+		{"leading v", "v11", "11.0.1", true},
+		{"pre-release lower than release", ">=11.0.0", "11.0.0-beta", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			r, err := ParseVersionRange(tt.versionRange)
+			if err != nil {
+				t.Fatalf("got error '%v' when none was expected", err)
+			}
+			if got := r.Contains(MustParseVersion(tt.version)); got != tt.want {
+				t.Errorf("expected '%s' contains '%s' to be %t, got %t", tt.versionRange, tt.version, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVersionRangeOverlaps(t *testing.T) {
+	var tests = []struct {
+		testName string
+		first    string
+		second   string
+		want     bool
+	}{
+		{"same major", "11", "11.2", true},
+		{"different majors", "10", "11", false},
+		{"range and version", ">=10, <12", "11.5.1", true},
+		{"adjacent ranges", "<11", ">=11", false},
+		{"all versions", "", "9.6", true},
+		{"alternatives", "9.6 || 12", "11 || 12.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			first := MustParseVersionRange(tt.first)
+			second := MustParseVersionRange(tt.second)
+			if got := first.Overlaps(second); got != tt.want {
+				t.Errorf("expected '%s' overlaps '%s' to be %t, got %t", tt.first, tt.second, tt.want, got)
+			}
+			if got := second.Overlaps(first); got != tt.want {
+				t.Errorf("expected '%s' overlaps '%s' to be %t, got %t", tt.second, tt.first, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVersionRangeHighest(t *testing.T) {
+	var tests = []struct {
+		testName string
+		r        string
+		want     string
+	}{
+		{"partial version", "11", "12.0.0"},
+		{"unbounded range", ">=10", "10.0.0"},
+		{"alternatives", "9.6 || 11.2.3", "11.2.3"},
+		{"all versions", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			highest, ok := MustParseVersionRange(tt.r).Highest()
+			if !ok {
+				if len(tt.want) > 0 {
+					t.Errorf("expected '%s' to have a highest bound", tt.r)
+				}
+				return
+			}
+			if got := highest.String(); got != tt.want {
+				t.Errorf("expected highest bound of '%s' to be %s, got %s", tt.r, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInvalidVersions(t *testing.T) {
+	for _, invalid := range []string{"a.b", "1.2.3.4", "1.x.3", ">=", "1.2-beta"} {
+		if _, err := ParseVersionRange(invalid); err == nil {
+			t.Errorf("expected '%s' to be an invalid version range", invalid)
+		}
+	}
+	for _, invalid := range []string{"11", "11.x", "*", ">=11.0.0"} {
+		if _, err := ParseVersion(invalid); err == nil {
+			t.Errorf("expected '%s' to be an invalid version", invalid)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package framework
+
+import (
+	"fmt"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+	"sync"
+)
+
+// WatchCallback is called by the reconciler so that dependents can add themselves as watched resources of the controller
+// reconciling their owner
+type WatchCallback func(owner SerializableResource, dependentGVK schema.GroupVersionKind) error
+
+// WatchedTypesDeclarer is an optional interface Resources can implement to declare the types of the dependents that need to be
+// watched on their behalf, so that RegisterNewReconciler sets up the associated watches when the controller is created instead
+// of when the first instance of the Resource is reconciled, which would miss the events occurring before. Dependents whose
+// type is only known at reconcile time, e.g. provided by plugins, and dependents the Resource doesn't own are still watched when
+// first reconciled if they are configured as Watched.
+type WatchedTypesDeclarer interface {
+	// GetWatchedTypes returns the configurations of the dependent types to watch on behalf of this Resource. Only their
+	// GroupVersionKind and EventFilter are taken into account.
+	GetWatchedTypes() []DependentResourceConfig
+}
+
+// WatchedTypesOf returns the configurations of the specified DependentResources that are configured as Watched and are owned by
+// their Resource, keeping the first one for each type, which can be used to implement WatchedTypesDeclarer based on the
+// dependents of a prototype Resource
+func WatchedTypesOf(dependents ...DependentResource) []DependentResourceConfig {
+	configs := make([]DependentResourceConfig, 0, len(dependents))
+	seen := make(map[schema.GroupVersionKind]bool, len(dependents))
+	for _, dependent := range dependents {
+		config := dependent.GetConfig()
+		if config.Watched && !isReferenced(config) && !seen[config.GroupVersionKind] {
+			seen[config.GroupVersionKind] = true
+			configs = append(configs, config)
+		}
+	}
+	return configs
+}
+
+// Watch describes a watch a controller set up on a dependent type on behalf of an owner type
+type Watch struct {
+	// Controller is the name of the controller owning the watch
+	Controller string
+	// OwnerType is the GroupVersionKind of the owner type whose instances are enqueued when watched objects change
+	OwnerType schema.GroupVersionKind
+	// DependentType is the GroupVersionKind of the watched dependent type
+	DependentType schema.GroupVersionKind
+	// ByReference records whether the owners to enqueue are looked up in the references recorded by the controller instead of
+	// using the owner references of the watched objects
+	ByReference bool
+}
+
+func (w Watch) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", w.Controller, w.DependentType, w.OwnerType)
+	if w.ByReference {
+		s += " (by reference)"
+	}
+	return s
+}
+
+// watchKey identifies a watch within a WatchRegistry
+type watchKey struct {
+	owner       schema.GroupVersionKind
+	dependent   schema.GroupVersionKind
+	byReference bool
+}
+
+// reference identifies an object a Resource depends on
+type reference struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// isReferenced determines whether the DependentResource with the specified configuration is merely referenced by its Resource,
+// in which case it cannot be watched using owner references since the Resource doesn't create it or doesn't own it
+func isReferenced(config DependentResourceConfig) bool {
+	return !config.Owned || !config.Created
+}
+
+// WatchRegistry records the watches a controller set up on dependent types, per owner type, so that a given watch is only set up
+// once. It also maintains an index of the objects referenced by the reconciled Resources, so that Resources depending on objects
+// they don't own are reconciled when these objects change. A WatchRegistry is safe for concurrent use.
+type WatchRegistry struct {
+	name       string
+	controller controller.Controller
+	mutex      sync.RWMutex
+	watched    map[watchKey]bool
+	// referencesMutex guards references and referencedBy
+	referencesMutex sync.RWMutex
+	// references records the Resources referencing a given object
+	references map[reference]map[types.NamespacedName]bool
+	// referencedBy records the objects a given Resource references so that they can be forgotten when they change
+	referencedBy map[types.NamespacedName][]reference
+}
+
+var (
+	// watchRegistries records the WatchRegistry of each controller, by controller name
+	watchRegistries = make(map[string]*WatchRegistry, 7)
+	registriesMutex = &sync.RWMutex{}
+)
+
+// newWatchRegistry creates a WatchRegistry for the specified controller and records it as the controller's registry
+func newWatchRegistry(name string, c controller.Controller) *WatchRegistry {
+	registry := &WatchRegistry{
+		name:         name,
+		controller:   c,
+		watched:      make(map[watchKey]bool, 7),
+		references:   make(map[reference]map[types.NamespacedName]bool, 7),
+		referencedBy: make(map[types.NamespacedName][]reference, 7),
+	}
+	registriesMutex.Lock()
+	defer registriesMutex.Unlock()
+	watchRegistries[name] = registry
+	return registry
+}
+
+// WatchRegistryFor returns the WatchRegistry of the controller reconciling the specified Resource, nil if no controller was
+// registered for it using RegisterNewReconciler
+func WatchRegistryFor(resource Resource) *WatchRegistry {
+	return watchRegistryNamed(controllerNameFor(resource.GetUnderlyingAPIResource()))
+}
+
+func watchRegistryNamed(name string) *WatchRegistry {
+	registriesMutex.RLock()
+	defer registriesMutex.RUnlock()
+	return watchRegistries[name]
+}
+
+// AllWatches lists the watches set up by all the registered controllers, sorted by controller name, then dependent type and
+// owner type, e.g. for debugging purposes
+func AllWatches() []Watch {
+	registriesMutex.RLock()
+	registries := make([]*WatchRegistry, 0, len(watchRegistries))
+	for _, registry := range watchRegistries {
+		registries = append(registries, registry)
+	}
+	registriesMutex.RUnlock()
+
+	watches := make([]Watch, 0, 7*len(registries))
+	for _, registry := range registries {
+		watches = append(watches, registry.Watches()...)
+	}
+	sortWatches(watches)
+	return watches
+}
+
+// Watch sets up a watch on the specified dependent type enqueuing the owner of changed objects, unless this controller already
+// watches this dependent type on behalf of the owner's type
+func (r *WatchRegistry) Watch(owner SerializableResource, dependentGVK schema.GroupVersionKind) error {
+	return r.watch(watchKey{owner: owner.GetGroupVersionKind(), dependent: dependentGVK}, EventFilter{})
+}
+
+// WatchReferences sets up a watch on the specified dependent type enqueuing the Resources referencing changed objects, as
+// recorded when they were last reconciled, unless this controller already does so on behalf of the owner's type. This allows
+// Resources to be notified of changes to the objects they depend on but don't own.
+func (r *WatchRegistry) WatchReferences(owner SerializableResource, dependentGVK schema.GroupVersionKind) error {
+	return r.watch(watchKey{owner: owner.GetGroupVersionKind(), dependent: dependentGVK, byReference: true}, EventFilter{})
+}
+
+// watch sets up the watch identified by the specified key, if needed, applying the given EventFilter to it
+func (r *WatchRegistry) watch(key watchKey, filter EventFilter) error {
+	r.mutex.RLock()
+	alreadyWatched := r.watched[key]
+	r.mutex.RUnlock()
+	if alreadyWatched {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	// check again in case the watch was set up concurrently
+	if r.watched[key] {
+		return nil
+	}
+	var eventHandler handler.EventHandler
+	if key.byReference {
+		eventHandler = &handler.EnqueueRequestsFromMapFunc{ToRequests: referenceMapper{registry: r, gvk: key.dependent}}
+	} else {
+		eventHandler = &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    CreateEmptyUnstructured(key.owner),
+		}
+	}
+	predicates, err := filter.Predicates()
+	if err != nil {
+		return err
+	}
+	if err := r.controller.Watch(createSourceForGVK(key.dependent), eventHandler, predicates...); err != nil {
+		return err
+	}
+	r.watched[key] = true
+	return nil
+}
+
+// watchDeclaredTypes sets up the watches on the dependent types declared by the specified Resource if it implements
+// WatchedTypesDeclarer
+func (r *WatchRegistry) watchDeclaredTypes(resource Resource) error {
+	declarer, ok := resource.(WatchedTypesDeclarer)
+	if !ok {
+		return nil
+	}
+	owner := resource.GetUnderlyingAPIResource().GetGroupVersionKind()
+	for _, config := range declarer.GetWatchedTypes() {
+		if err := r.watch(watchKey{owner: owner, dependent: config.GroupVersionKind}, config.EventFilter); err != nil {
+			return fmt.Errorf("failed to watch %s on behalf of %s: %w", config.GroupVersionKind, owner, err)
+		}
+	}
+	return nil
+}
+
+// watchDependents sets up the watches needed by the Watched dependents of the specified Resource and records the objects it
+// references, forgetting the ones it previously referenced
+func (r *WatchRegistry) watchDependents(resource types.NamespacedName, dependents []DependentResource) error {
+	references := make([]reference, 0, len(dependents))
+	for _, dependent := range dependents {
+		config := dependent.GetConfig()
+		if !config.Watched {
+			continue
+		}
+		key := watchKey{owner: dependent.Owner().GetGroupVersionKind(), dependent: config.GroupVersionKind, byReference: isReferenced(config)}
+		if err := r.watch(key, config.EventFilter); err != nil {
+			return err
+		}
+		if key.byReference {
+			references = append(references, reference{gvk: config.GroupVersionKind, namespace: resource.Namespace, name: dependent.Name()})
+		}
+	}
+	r.setReferences(resource, references)
+	return nil
+}
+
+// setReferences records that the specified Resource references the given objects, replacing the ones it previously referenced
+func (r *WatchRegistry) setReferences(resource types.NamespacedName, references []reference) {
+	r.referencesMutex.Lock()
+	defer r.referencesMutex.Unlock()
+	for _, ref := range r.referencedBy[resource] {
+		if referencing := r.references[ref]; referencing != nil {
+			delete(referencing, resource)
+			if len(referencing) == 0 {
+				delete(r.references, ref)
+			}
+		}
+	}
+	if len(references) == 0 {
+		delete(r.referencedBy, resource)
+		return
+	}
+	for _, ref := range references {
+		referencing := r.references[ref]
+		if referencing == nil {
+			referencing = make(map[types.NamespacedName]bool, 1)
+			r.references[ref] = referencing
+		}
+		referencing[resource] = true
+	}
+	r.referencedBy[resource] = references
+}
+
+// forget removes the objects referenced by the specified Resource from the index, e.g. when it's deleted
+func (r *WatchRegistry) forget(resource types.NamespacedName) {
+	r.setReferences(resource, nil)
+}
+
+// Referencing returns the reconcile requests for the Resources referencing the object with the specified type, namespace and
+// name, as recorded when they were last reconciled, sorted by namespace and name
+func (r *WatchRegistry) Referencing(gvk schema.GroupVersionKind, namespace, name string) []reconcile.Request {
+	r.referencesMutex.RLock()
+	referencing := r.references[reference{gvk: gvk, namespace: namespace, name: name}]
+	requests := make([]reconcile.Request, 0, len(referencing))
+	for resource := range referencing {
+		requests = append(requests, reconcile.Request{NamespacedName: resource})
+	}
+	r.referencesMutex.RUnlock()
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].String() < requests[j].String()
+	})
+	return requests
+}
+
+// referenceMapper maps objects of a given type to the Resources referencing them
+type referenceMapper struct {
+	registry *WatchRegistry
+	gvk      schema.GroupVersionKind
+}
+
+func (m referenceMapper) Map(o handler.MapObject) []reconcile.Request {
+	return m.registry.Referencing(m.gvk, o.Meta.GetNamespace(), o.Meta.GetName())
+}
+
+// IsWatching determines whether this controller watches the specified dependent type on behalf of the given owner type, using
+// owner references
+func (r *WatchRegistry) IsWatching(ownerGVK, dependentGVK schema.GroupVersionKind) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.watched[watchKey{owner: ownerGVK, dependent: dependentGVK}]
+}
+
+// Watches lists the watches this controller set up, sorted by dependent type then owner type
+func (r *WatchRegistry) Watches() []Watch {
+	r.mutex.RLock()
+	watches := make([]Watch, 0, len(r.watched))
+	for key := range r.watched {
+		watches = append(watches, Watch{Controller: r.name, OwnerType: key.owner, DependentType: key.dependent, ByReference: key.byReference})
+	}
+	r.mutex.RUnlock()
+	sortWatches(watches)
+	return watches
+}
+
+func sortWatches(watches []Watch) {
+	sort.Slice(watches, func(i, j int) bool {
+		if watches[i].Controller != watches[j].Controller {
+			return watches[i].Controller < watches[j].Controller
+		}
+		if d1, d2 := watches[i].DependentType.String(), watches[j].DependentType.String(); d1 != d2 {
+			return d1 < d2
+		}
+		if o1, o2 := watches[i].OwnerType.String(), watches[j].OwnerType.String(); o1 != o2 {
+			return o1 < o2
+		}
+		return !watches[i].ByReference && watches[j].ByReference
+	})
+}
@@ -0,0 +1,235 @@
+package framework
+
+import (
+	halkyon "halkyon.io/api/capability/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sync"
+	"testing"
+)
+
+// countingController is a controller.Controller recording how many watches were set up
+type countingController struct {
+	mutex   sync.Mutex
+	watches int
+}
+
+func (c *countingController) Reconcile(reconcile.Request) (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+func (c *countingController) Watch(source.Source, handler.EventHandler, ...predicate.Predicate) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.watches++
+	return nil
+}
+
+func (c *countingController) Start(<-chan struct{}) error {
+	return nil
+}
+
+// typedOwner is a SerializableResource with a configurable GroupVersionKind
+type typedOwner struct {
+	*halkyon.Capability
+	gvk schema.GroupVersionKind
+}
+
+func (o typedOwner) GetGroupVersionKind() schema.GroupVersionKind {
+	return o.gvk
+}
+
+func TestWatchRegistriesAreScopedPerController(t *testing.T) {
+	secretGVK := corev1.SchemeGroupVersion.WithKind("Secret")
+	component := typedOwner{Capability: &halkyon.Capability{}, gvk: halkyon.SchemeGroupVersion.WithKind("Component")}
+	capability := typedOwner{Capability: &halkyon.Capability{}, gvk: halkyon.SchemeGroupVersion.WithKind("Capability")}
+
+	componentController, capabilityController := &countingController{}, &countingController{}
+	componentWatches := newWatchRegistry("test-component-controller", componentController)
+	capabilityWatches := newWatchRegistry("test-capability-controller", capabilityController)
+	defer func() {
+		registriesMutex.Lock()
+		delete(watchRegistries, "test-component-controller")
+		delete(watchRegistries, "test-capability-controller")
+		registriesMutex.Unlock()
+	}()
+
+	// concurrent reconciles of the same controller only set up the watch once
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := componentWatches.Watch(component, secretGVK); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if componentController.watches != 1 {
+		t.Errorf("expected component controller to set up 1 watch, got %d", componentController.watches)
+	}
+
+	// another controller watching the same dependent type still gets its own watch
+	if err := capabilityWatches.Watch(capability, secretGVK); err != nil {
+		t.Fatal(err)
+	}
+	if capabilityController.watches != 1 {
+		t.Errorf("expected capability controller to set up 1 watch, got %d", capabilityController.watches)
+	}
+	if !capabilityWatches.IsWatching(capability.gvk, secretGVK) {
+		t.Error("expected capability controller to watch secrets on behalf of capabilities")
+	}
+	if capabilityWatches.IsWatching(component.gvk, secretGVK) {
+		t.Error("didn't expect capability controller to watch secrets on behalf of components")
+	}
+
+	watches := AllWatches()
+	expected := []Watch{
+		{Controller: "test-capability-controller", OwnerType: capability.gvk, DependentType: secretGVK},
+		{Controller: "test-component-controller", OwnerType: component.gvk, DependentType: secretGVK},
+	}
+	if len(watches) != len(expected) {
+		t.Fatalf("expected watches %v, got %v", expected, watches)
+	}
+	for i := range expected {
+		if watches[i] != expected[i] {
+			t.Errorf("expected watch #%d to be '%s', got '%s'", i, expected[i], watches[i])
+		}
+	}
+}
+
+// declaringResource is a Resource declaring the dependent types to watch on its behalf
+type declaringResource struct {
+	Resource
+	owner typedOwner
+	types []DependentResourceConfig
+}
+
+func (d declaringResource) GetUnderlyingAPIResource() SerializableResource {
+	return d.owner
+}
+
+func (d declaringResource) GetWatchedTypes() []DependentResourceConfig {
+	return d.types
+}
+
+func TestDeclaredTypesAreWatchedUpFront(t *testing.T) {
+	secretGVK := corev1.SchemeGroupVersion.WithKind("Secret")
+	serviceGVK := corev1.SchemeGroupVersion.WithKind("Service")
+	owner := typedOwner{Capability: &halkyon.Capability{}, gvk: halkyon.SchemeGroupVersion.WithKind("Component")}
+	resource := declaringResource{owner: owner, types: []DependentResourceConfig{NewConfig(secretGVK), NewConfig(serviceGVK)}}
+
+	c := &countingController{}
+	registry := &WatchRegistry{name: "test-declaring-controller", controller: c, watched: make(map[watchKey]bool, 7)}
+	if err := registry.watchDeclaredTypes(resource); err != nil {
+		t.Fatal(err)
+	}
+	if c.watches != 2 {
+		t.Errorf("expected 2 watches to be set up, got %d", c.watches)
+	}
+	for _, config := range resource.types {
+		if !registry.IsWatching(owner.gvk, config.GroupVersionKind) {
+			t.Errorf("expected %s to be watched", config.GroupVersionKind)
+		}
+	}
+
+	// dependents of declared types don't set up another watch when first reconciled
+	if err := registry.Watch(owner, secretGVK); err != nil {
+		t.Fatal(err)
+	}
+	if c.watches != 2 {
+		t.Errorf("expected 2 watches to be set up, got %d", c.watches)
+	}
+}
+
+// referencedDependent is a DependentResource a Resource depends on without owning it
+type referencedDependent struct {
+	DependentResource
+	name   string
+	owner  SerializableResource
+	config DependentResourceConfig
+}
+
+func (d referencedDependent) Name() string {
+	return d.name
+}
+
+func (d referencedDependent) Owner() SerializableResource {
+	return d.owner
+}
+
+func (d referencedDependent) GetConfig() DependentResourceConfig {
+	return d.config
+}
+
+func TestReferencedDependentsEnqueueReferencingResources(t *testing.T) {
+	capabilityGVK := halkyon.SchemeGroupVersion.WithKind("Capability")
+	owner := typedOwner{Capability: &halkyon.Capability{}, gvk: halkyon.SchemeGroupVersion.WithKind("Component")}
+	config := DependentResourceConfig{Watched: true, GroupVersionKind: capabilityGVK}
+	dependentsOf := func(names ...string) []DependentResource {
+		dependents := make([]DependentResource, 0, len(names))
+		for _, name := range names {
+			dependents = append(dependents, referencedDependent{name: name, owner: owner, config: config})
+		}
+		return dependents
+	}
+	frontend := types.NamespacedName{Namespace: "test", Name: "frontend"}
+	backend := types.NamespacedName{Namespace: "test", Name: "backend"}
+
+	c := &countingController{}
+	registry := &WatchRegistry{
+		name:         "test-referencing-controller",
+		controller:   c,
+		watched:      make(map[watchKey]bool, 7),
+		references:   make(map[reference]map[types.NamespacedName]bool, 7),
+		referencedBy: make(map[types.NamespacedName][]reference, 7),
+	}
+	if err := registry.watchDependents(frontend, dependentsOf("db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.watchDependents(backend, dependentsOf("db", "cache")); err != nil {
+		t.Fatal(err)
+	}
+	if c.watches != 1 {
+		t.Errorf("expected 1 watch to be set up, got %d", c.watches)
+	}
+	if watches := registry.Watches(); len(watches) != 1 || !watches[0].ByReference {
+		t.Errorf("expected capabilities to be watched by reference, got %v", watches)
+	}
+	if registry.IsWatching(owner.gvk, capabilityGVK) {
+		t.Error("didn't expect capabilities to be watched using owner references")
+	}
+
+	mapper := referenceMapper{registry: registry, gvk: capabilityGVK}
+	db := &halkyon.Capability{ObjectMeta: v1.ObjectMeta{Namespace: "test", Name: "db"}}
+	assertRequests(t, mapper.Map(handler.MapObject{Meta: db, Object: db}), backend, frontend)
+
+	// references are replaced when a resource is reconciled again and forgotten when it's deleted
+	if err := registry.watchDependents(backend, dependentsOf("cache")); err != nil {
+		t.Fatal(err)
+	}
+	assertRequests(t, mapper.Map(handler.MapObject{Meta: db, Object: db}), frontend)
+	registry.forget(frontend)
+	assertRequests(t, mapper.Map(handler.MapObject{Meta: db, Object: db}))
+	assertRequests(t, registry.Referencing(capabilityGVK, "test", "cache"), backend)
+	assertRequests(t, registry.Referencing(capabilityGVK, "other", "cache"))
+}
+
+func assertRequests(t *testing.T, requests []reconcile.Request, expected ...types.NamespacedName) {
+	t.Helper()
+	if len(requests) != len(expected) {
+		t.Fatalf("expected requests for %v, got %v", expected, requests)
+	}
+	for i, request := range requests {
+		if request.NamespacedName != expected[i] {
+			t.Errorf("expected request #%d to be for '%s', got '%s'", i, expected[i], request.NamespacedName)
+		}
+	}
+}